@@ -1,10 +1,17 @@
 package riff
 
 import (
+	"strconv"
 	"sync"
+	"unicode/utf8"
 )
 
-const bufferSize = 1024
+const (
+	bufferSize = 1024
+	// Larger buffers are not returned to the pool, so that a single huge entry
+	// doesn't keep the memory forever.
+	maxBufferSize = 64 << 10
+)
 
 // Buffers are pooled to reduce allocations.
 var bufferPool = sync.Pool{
@@ -21,5 +28,31 @@ func getBuffer() *[]byte {
 }
 
 func putBuffer(buf *[]byte) {
+	if cap(*buf) > maxBufferSize {
+		return
+	}
 	bufferPool.Put(buf)
 }
+
+// truncate cuts the part of the buffer past start down to max bytes on a rune
+// boundary and appends a marker with the number of bytes cut. It reports
+// whether anything was cut.
+func truncate(buf *[]byte, start, max int) bool {
+	// Kept small enough to be inlined, limits are off by default
+	if max <= 0 || len(*buf)-start <= max {
+		return false
+	}
+	cutBuffer(buf, start, max)
+	return true
+}
+
+func cutBuffer(buf *[]byte, start, max int) {
+	end := start + max
+	for end > start && !utf8.RuneStart((*buf)[end]) {
+		end--
+	}
+	cut := len(*buf) - end
+	*buf = append((*buf)[:end], "…(truncated "...)
+	*buf = strconv.AppendInt(*buf, int64(cut), 10)
+	*buf = append(*buf, " bytes)"...)
+}
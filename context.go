@@ -14,3 +14,17 @@ func FromContext(ctx context.Context) []Field {
 	v, _ := ctx.Value(contextKey{}).([]Field)
 	return v
 }
+
+type levelKey struct{}
+
+// WithLevel returns a context that enables entries at the given level and above
+// for loggers with ContextLevels enabled, regardless of their configured level.
+func WithLevel(ctx context.Context, lev Level) context.Context {
+	return context.WithValue(ctx, levelKey{}, lev)
+}
+
+// LevelFromContext returns the level set with WithLevel, if any.
+func LevelFromContext(ctx context.Context) (Level, bool) {
+	lev, ok := ctx.Value(levelKey{}).(Level)
+	return lev, ok
+}
@@ -2,56 +2,143 @@ package log
 
 import (
 	"context"
+	"fmt"
+	"sync/atomic"
 	"time"
 
 	"github.com/localhots/riff"
 )
 
-var logger *riff.Logger
+// logger is the global logger, it writes to stderr at the Info level until
+// Setup or Replace is called.
+var logger atomic.Pointer[riff.Logger]
 
-// Setup initializes the logger.
+func init() {
+	logger.Store(riff.New(riff.DefaultConfig()))
+}
+
+// Setup replaces the global logger with a new one created from the config.
+// It is safe to call while other goroutines are logging.
 func Setup(cfg riff.Config) {
-	logger = riff.New(cfg)
+	logger.Store(riff.New(cfg))
+}
+
+// Replace replaces the global logger and returns a function that restores the
+// previous one, which is handy in tests.
+func Replace(l *riff.Logger) (restore func()) {
+	prev := logger.Swap(l)
+	return func() {
+		logger.Store(prev)
+	}
+}
+
+// L returns the global logger.
+func L() *riff.Logger {
+	return logger.Load()
+}
+
+// Named returns a logger for a component with the given name.
+func Named(name string) *riff.Logger {
+	return L().Named(name)
+}
+
+// SetLevelSpec replaces the level spec of the global logger.
+func SetLevelSpec(spec *riff.LevelSpec) {
+	L().SetLevelSpec(spec)
 }
 
 // Trace logs a message at the Trace level, which is the most verbose level.
 func Trace(ctx context.Context, msg string, fields ...riff.Field) {
-	logger.Trace(ctx, msg, fields...)
+	L().Trace(ctx, msg, fields...)
 }
 
 // Debug logs a message at the Debug level, which is less verbose than Trace,
 // but is still excessively detailed.
 func Debug(ctx context.Context, msg string, fields ...riff.Field) {
-	logger.Debug(ctx, msg, fields...)
+	L().Debug(ctx, msg, fields...)
 }
 
 // Info logs a message at the Info level, which is great for general kind of
 // records.
 func Info(ctx context.Context, msg string, fields ...riff.Field) {
-	logger.Info(ctx, msg, fields...)
+	L().Info(ctx, msg, fields...)
 }
 
 // Warn logs a message at the Warn level, which indicates a potential problem
 // that should be looked at.
 func Warn(ctx context.Context, msg string, fields ...riff.Field) {
-	logger.Warn(ctx, msg, fields...)
+	L().Warn(ctx, msg, fields...)
 }
 
 // Error logs a message at the Error level.
 func Error(ctx context.Context, msg string, fields ...riff.Field) {
-	logger.Error(ctx, msg, fields...)
+	L().Error(ctx, msg, fields...)
 }
 
 // Panic logs a message at the Panic level, which indicates a very serious
 // problem. It doesn't actually panic, but it should be treated as an emergency.
 func Panic(ctx context.Context, msg string, fields ...riff.Field) {
-	logger.Panic(ctx, msg, fields...)
+	L().Panic(ctx, msg, fields...)
 }
 
 // Fatal logs a message at the Fatal level, which indicates an unrecoverable
 // error. It will terminate the program after logging the message.
 func Fatal(ctx context.Context, msg string, fields ...riff.Field) {
-	logger.Fatal(ctx, msg, fields...)
+	L().Fatal(ctx, msg, fields...)
+}
+
+// Log logs a message at the given level, which can be a custom one. It exits
+// the program after logging at the Fatal level, like Fatal does.
+func Log(ctx context.Context, lev riff.Level, msg string, fields ...riff.Field) {
+	L().Log(ctx, lev, msg, fields...)
+}
+
+// Enabled reports whether messages at the given level are logged, taking the
+// level set on the context into account.
+func Enabled(ctx context.Context, lev riff.Level) bool {
+	return L().Enabled(ctx, lev)
+}
+
+// Tracef formats and logs a message at the Trace level.
+func Tracef(ctx context.Context, format string, args ...any) {
+	L().Tracef(ctx, format, args...)
+}
+
+// Debugf formats and logs a message at the Debug level.
+func Debugf(ctx context.Context, format string, args ...any) {
+	L().Debugf(ctx, format, args...)
+}
+
+// Infof formats and logs a message at the Info level.
+func Infof(ctx context.Context, format string, args ...any) {
+	L().Infof(ctx, format, args...)
+}
+
+// Warnf formats and logs a message at the Warn level.
+func Warnf(ctx context.Context, format string, args ...any) {
+	L().Warnf(ctx, format, args...)
+}
+
+// Errorf formats and logs a message at the Error level.
+func Errorf(ctx context.Context, format string, args ...any) {
+	L().Errorf(ctx, format, args...)
+}
+
+// Panicf formats and logs a message at the Panic level.
+func Panicf(ctx context.Context, format string, args ...any) {
+	L().Panicf(ctx, format, args...)
+}
+
+// Fatalf formats and logs a message at the Fatal level. It terminates the
+// program after logging the message.
+func Fatalf(ctx context.Context, format string, args ...any) {
+	L().Fatalf(ctx, format, args...)
+}
+
+// Event starts a new entry at the given level. It returns nil if the level is
+// disabled.
+func Event(ctx context.Context, lev riff.Level) *riff.Event {
+	return L().Event(ctx, lev)
 }
 
 // WithContext adds logging fields to the context.
@@ -64,6 +151,18 @@ func FromContext(ctx context.Context) []riff.Field {
 	return riff.FromContext(ctx)
 }
 
+// WithLevel returns a context that enables entries at the given level and
+// above, regardless of the configured level.
+func WithLevel(ctx context.Context, lev riff.Level) context.Context {
+	return riff.WithLevel(ctx, lev)
+}
+
+// WithBuffer returns a context that holds back entries below the logger level
+// until an error is logged with it.
+func WithBuffer(ctx context.Context, capacity int) context.Context {
+	return riff.WithBuffer(ctx, capacity)
+}
+
 //
 // Types
 //
@@ -158,7 +257,116 @@ func Time(key string, value time.Time) riff.Field {
 	return riff.Time(key, value)
 }
 
+// Group returns a Field that groups the given fields under the key.
+func Group(key string, fields ...riff.Field) riff.Field {
+	return riff.Group(key, fields...)
+}
+
+// Object returns a Field with the given key and a value that is written by its
+// LogObject method.
+func Object(key string, value riff.ObjectMarshaler) riff.Field {
+	return riff.Object(key, value)
+}
+
+// Array returns a Field with the given key and a value that is written by its
+// LogArray method.
+func Array(key string, value riff.ArrayMarshaler) riff.Field {
+	return riff.Array(key, value)
+}
+
+// Strs returns a Field with the given key and a slice of strings value.
+func Strs(key string, values []string) riff.Field {
+	return riff.Strs(key, values)
+}
+
+// Ints returns a Field with the given key and a slice of ints value.
+func Ints(key string, values []int) riff.Field {
+	return riff.Ints(key, values)
+}
+
+// Int64s returns a Field with the given key and a slice of int64s value.
+func Int64s(key string, values []int64) riff.Field {
+	return riff.Int64s(key, values)
+}
+
+// Floats returns a Field with the given key and a slice of float64s value.
+func Floats(key string, values []float64) riff.Field {
+	return riff.Floats(key, values)
+}
+
+// Bools returns a Field with the given key and a slice of booleans value.
+func Bools(key string, values []bool) riff.Field {
+	return riff.Bools(key, values)
+}
+
+// Durations returns a Field with the given key and a slice of time.Duration value.
+func Durations(key string, values []time.Duration) riff.Field {
+	return riff.Durations(key, values)
+}
+
+// Times returns a Field with the given key and a slice of time.Time value.
+func Times(key string, values []time.Time) riff.Field {
+	return riff.Times(key, values)
+}
+
+// StrMap returns a Field with the given key and a map of strings value.
+func StrMap(key string, values map[string]string) riff.Field {
+	return riff.StrMap(key, values)
+}
+
+// Struct returns a Field with the given key and a struct value that is
+// printed using reflection.
+func Struct(key string, value any) riff.Field {
+	return riff.Struct(key, value)
+}
+
+// Stringer returns a Field with the given key and a value that is converted
+// to a string only when the entry is written.
+func Stringer(key string, value fmt.Stringer) riff.Field {
+	return riff.Stringer(key, value)
+}
+
+// Secret returns a Field with the given key and a value that is never
+// written.
+func Secret(key string, value any) riff.Field {
+	return riff.Secret(key, value)
+}
+
+// Lazy returns a Field with the given key and a value that is only computed
+// when the entry is written.
+func Lazy(key string, fn func() any) riff.Field {
+	return riff.Lazy(key, fn)
+}
+
+// LazyStr returns a Field with the given key and a string value that is only
+// computed when the entry is written.
+func LazyStr(key string, fn func() string) riff.Field {
+	return riff.LazyStr(key, fn)
+}
+
 // Any returns a Field with the given key and any value.
 func Any(key string, value any) riff.Field {
 	return riff.Any(key, value)
 }
+
+// Stack returns a Field that forces a stack trace to be printed with the entry.
+func Stack() riff.Field {
+	return riff.Stack()
+}
+
+// StackOf returns a Field that forces a stack trace of the given scope to be
+// printed with the entry.
+func StackOf(scope riff.StackScope) riff.Field {
+	return riff.StackOf(scope)
+}
+
+// AllGoroutines returns a Field that forces the stacks of all goroutines to be
+// printed with the entry.
+func AllGoroutines() riff.Field {
+	return riff.AllGoroutines()
+}
+
+// NoStack returns a Field that suppresses the stack trace for the entry.
+func NoStack() riff.Field {
+	return riff.NoStack()
+}
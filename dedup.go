@@ -0,0 +1,112 @@
+package riff
+
+import (
+	"bytes"
+	"time"
+)
+
+// deduper collapses consecutive duplicate entries. The first entry is written
+// as is, the repeats that follow are counted and replaced by a single summary
+// entry once a different entry arrives or the timeout passes. It is guarded by
+// the logger lock.
+type deduper struct {
+	timeout time.Duration
+	timer   *time.Timer
+
+	// Last written entry without the timestamp
+	last      []byte
+	lastLine  int
+	lastLevel Level
+	hasFields bool
+
+	// Repeats of the last entry that were held back
+	count     int
+	firstSeen time.Time
+	lastSeen  time.Time
+}
+
+func newDeduper(timeout time.Duration) *deduper {
+	return &deduper{timeout: timeout}
+}
+
+// writeDeduped writes the entry unless it repeats the previous one. The body
+// and line offsets mark the beginning of the entry after the timestamp and the
+// end of its first line.
+func (l *Logger) writeDeduped(buf []byte, lev Level, body, line int, hasFields bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	d := l.dedup
+	if d.last != nil && bytes.Equal(buf[body:], d.last) {
+		now := time.Now()
+		if d.count == 0 {
+			d.firstSeen = now
+			if d.timer == nil {
+				d.timer = time.AfterFunc(d.timeout, l.Flush)
+			} else {
+				d.timer.Reset(d.timeout)
+			}
+		}
+		d.count++
+		d.lastSeen = now
+		return
+	}
+
+	l.flushRepeats()
+	d.last = append(d.last[:0], buf[body:]...)
+	d.lastLine = line - body
+	d.lastLevel = lev
+	d.hasFields = hasFields
+	l.cfg.Output.Write(buf)
+}
+
+// Flush writes pending entries that are being held back.
+func (l *Logger) Flush() {
+	if l.dedup == nil {
+		return
+	}
+
+	l.lock.Lock()
+	l.flushRepeats()
+	l.lock.Unlock()
+}
+
+// flushRepeats writes a summary entry for the repeats of the last entry. It
+// must be called with the logger lock held.
+func (l *Logger) flushRepeats() {
+	d := l.dedup
+	if d.count == 0 {
+		return
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	l.printTime(buf)
+	*buf = append(*buf, d.last[:d.lastLine]...)
+	switch {
+	case d.hasFields:
+		*buf = append(*buf, ' ')
+	case l.cfg.MinMessageWidth == 0:
+		// Separate message from fields with 2 spaces, padded messages are
+		// already separated
+		*buf = append(*buf, ' ', ' ')
+	}
+	l.printField(buf, d.lastLevel, Int("repeated", d.count), false)
+	l.printField(buf, d.lastLevel, l.timeField("first", d.firstSeen), true)
+	l.printField(buf, d.lastLevel, l.timeField("last", d.lastSeen), true)
+	*buf = append(*buf, '\n')
+	l.cfg.Output.Write(*buf)
+
+	d.count = 0
+}
+
+// timeField returns a time field formatted the same way entry timestamps are.
+func (l *Logger) timeField(key string, t time.Time) Field {
+	if l.cfg.TimeFormat == "" {
+		return Time(key, t)
+	}
+	return field(key, func(b []byte) []byte {
+		return t.AppendFormat(b, l.cfg.TimeFormat)
+	})
+}
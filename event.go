@@ -0,0 +1,278 @@
+package riff
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Event is a log entry that is built by chaining field methods and written
+// with Msg. Fields are encoded straight into a pooled buffer as they are
+// added, in the order they are added, ahead of the context fields.
+//
+// A nil Event is returned for disabled levels, all of its methods are no-ops
+// so that fields are not encoded at all. An Event must not be used after Msg
+// is called.
+type Event struct {
+	l     *Logger
+	ctx   context.Context
+	level Level
+	pc    uintptr
+	buf   *[]byte
+	stack fieldKind
+	tail  *tailBuffer // Set if the entry goes to the tail buffer
+}
+
+var eventPool = sync.Pool{
+	New: func() any {
+		return &Event{}
+	},
+}
+
+// Event starts a new entry at the given level. It returns nil if the level is
+// disabled.
+func (l *Logger) Event(ctx context.Context, lev Level) *Event {
+	// Event is one frame above print, +1 frame for admit
+	pc, ok, tail := l.admit(ctx, lev, 2)
+	if !ok && tail == nil {
+		return nil
+	}
+
+	e, _ := eventPool.Get().(*Event)
+	e.l = l
+	e.ctx = ctx
+	e.level = lev
+	e.pc = pc
+	e.buf = getBuffer()
+	e.stack = kindValue
+	e.tail = tail
+	return e
+}
+
+// Msg writes the entry with the given message and releases the event.
+func (e *Event) Msg(msg string) {
+	if e == nil {
+		return
+	}
+
+	en := entry{
+		level:     e.level,
+		msg:       msg,
+		ctxFields: FromContext(e.ctx),
+		encoded:   *e.buf,
+		stack:     e.stack,
+		pc:        e.pc,
+	}
+	if e.tail != nil {
+		e.l.bufferEntry(e.tail, &en)
+	} else {
+		// Msg and output take the place of print and the level method, and
+		// there is no package level wrapper
+		e.l.output(e.ctx, &en, e.l.cfg.StackTraceSkip-1)
+	}
+
+	putBuffer(e.buf)
+	*e = Event{}
+	eventPool.Put(e)
+}
+
+// Field adds an arbitrary field to the entry.
+func (e *Event) Field(f Field) *Event {
+	if e == nil {
+		return nil
+	}
+	if f.isDirective() {
+		e.stack = f.kind()
+		return e
+	}
+	e.l.printFieldPrefixed(e.buf, e.level, e.l.group, &f, len(*e.buf) > 0)
+	return e
+}
+
+// Fields adds arbitrary fields to the entry.
+func (e *Event) Fields(fields ...Field) *Event {
+	for _, f := range fields {
+		e = e.Field(f)
+	}
+	return e
+}
+
+// Str adds a string field to the entry.
+func (e *Event) Str(key, value string) *Event {
+	if e == nil {
+		return nil
+	}
+	if e.l.redactor != nil {
+		return e.Field(Str(key, value))
+	}
+	styled := e.key(key, typeString)
+	start := len(*e.buf)
+	*e.buf = append(*e.buf, value...)
+	e.l.finishValue(e.buf, start)
+	e.l.endStyle(e.buf, styled)
+	return e
+}
+
+// Int adds an int field to the entry.
+func (e *Event) Int(key string, value int) *Event {
+	return e.Int64(key, int64(value))
+}
+
+// Int64 adds an int64 field to the entry.
+func (e *Event) Int64(key string, value int64) *Event {
+	if e == nil {
+		return nil
+	}
+	if e.l.redactor != nil {
+		return e.Field(Int64(key, value))
+	}
+	styled := e.key(key, typeNumber)
+	*e.buf = strconv.AppendInt(*e.buf, value, 10)
+	e.l.endStyle(e.buf, styled)
+	return e
+}
+
+// Uint adds a uint field to the entry.
+func (e *Event) Uint(key string, value uint) *Event {
+	return e.Uint64(key, uint64(value))
+}
+
+// Uint64 adds a uint64 field to the entry.
+func (e *Event) Uint64(key string, value uint64) *Event {
+	if e == nil {
+		return nil
+	}
+	if e.l.redactor != nil {
+		return e.Field(Uint64(key, value))
+	}
+	styled := e.key(key, typeNumber)
+	*e.buf = strconv.AppendUint(*e.buf, value, 10)
+	e.l.endStyle(e.buf, styled)
+	return e
+}
+
+// Float64 adds a float64 field to the entry.
+func (e *Event) Float64(key string, value float64) *Event {
+	if e == nil {
+		return nil
+	}
+	if e.l.redactor != nil {
+		return e.Field(Float64(key, value))
+	}
+	styled := e.key(key, typeNumber)
+	*e.buf = strconv.AppendFloat(*e.buf, value, 'f', -1, 64)
+	e.l.endStyle(e.buf, styled)
+	return e
+}
+
+// Bool adds a boolean field to the entry.
+func (e *Event) Bool(key string, value bool) *Event {
+	if e == nil {
+		return nil
+	}
+	if e.l.redactor != nil {
+		return e.Field(Bool(key, value))
+	}
+	styled := e.key(key, typeOther)
+	*e.buf = strconv.AppendBool(*e.buf, value)
+	e.l.endStyle(e.buf, styled)
+	return e
+}
+
+// Duration adds a time.Duration field to the entry. The duration is truncated
+// to the configured precision.
+func (e *Event) Duration(key string, value time.Duration) *Event {
+	if e == nil {
+		return nil
+	}
+	if e.l.redactor != nil {
+		return e.Field(Duration(key, value))
+	}
+	styled := e.key(key, typeDuration)
+	*e.buf = append(*e.buf, value.Truncate(DurationPrecision).String()...)
+	e.l.endStyle(e.buf, styled)
+	return e
+}
+
+// Time adds a time.Time field to the entry. Time is formatted using the
+// configured time format.
+func (e *Event) Time(key string, value time.Time) *Event {
+	if e == nil {
+		return nil
+	}
+	if e.l.redactor != nil {
+		return e.Field(Time(key, value))
+	}
+	styled := e.key(key, typeOther)
+	*e.buf = value.AppendFormat(*e.buf, TimeFormat)
+	e.l.endStyle(e.buf, styled)
+	return e
+}
+
+// Object adds a field with a value that is written by its LogObject method.
+func (e *Event) Object(key string, value ObjectMarshaler) *Event {
+	if e == nil {
+		return nil
+	}
+	if e.l.redactor != nil {
+		return e.Field(Object(key, value))
+	}
+	styled := e.key(key, typeOther)
+	start := len(*e.buf)
+	*e.buf = appendObject(*e.buf, value)
+	e.l.finishValue(e.buf, start)
+	e.l.endStyle(e.buf, styled)
+	return e
+}
+
+// Array adds a field with a value that is written by its LogArray method.
+func (e *Event) Array(key string, value ArrayMarshaler) *Event {
+	if e == nil {
+		return nil
+	}
+	if e.l.redactor != nil {
+		return e.Field(Array(key, value))
+	}
+	styled := e.key(key, typeOther)
+	start := len(*e.buf)
+	*e.buf = appendArray(*e.buf, value)
+	e.l.finishValue(e.buf, start)
+	e.l.endStyle(e.buf, styled)
+	return e
+}
+
+// Cause adds the error to the entry in a standardized way.
+func (e *Event) Cause(err error) *Event {
+	if e == nil {
+		return nil
+	}
+	if e.l.redactor != nil {
+		return e.Field(Cause(err))
+	}
+	styled := e.key("error", typeError)
+	start := len(*e.buf)
+	*e.buf = append(*e.buf, err.Error()...)
+	e.l.finishValue(e.buf, start)
+	e.l.endStyle(e.buf, styled)
+	return e
+}
+
+// Stack forces a stack trace to be printed with the entry.
+func (e *Event) Stack() *Event {
+	return e.Field(Stack())
+}
+
+// NoStack suppresses the stack trace for the entry.
+func (e *Event) NoStack() *Event {
+	return e.Field(NoStack())
+}
+
+// key writes the key of the next field and the style of its value. It reports
+// whether the style needs to be reset after the value.
+func (e *Event) key(key string, typ valueType) bool {
+	e.l.printSeparator(e.buf, len(*e.buf) > 0)
+	e.l.printKey(e.buf, e.level, e.l.group, key)
+	e.l.printAssign(e.buf)
+	return e.l.startStyle(e.buf, e.l.valueStyle(typ))
+}
@@ -0,0 +1,181 @@
+package riff
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// printfFormat is a parsed printf-style format string. Besides the standard
+// verbs it supports naming arguments with the %{name}d extension.
+type printfFormat struct {
+	// Format with argument names removed, as understood by fmt
+	text string
+	// Names of the arguments that are consumed by verbs, empty for unnamed
+	// ones and for width and precision arguments
+	names []string
+}
+
+// Parsed formats. Formats are normally string literals, but messages built at
+// runtime are passed as formats too, so the cache size is capped the same way
+// the message template cache is.
+var (
+	printfFormats     = map[string]*printfFormat{}
+	printfFormatsLock sync.RWMutex
+)
+
+const maxPrintfFormats = 1024
+
+func (l *Logger) Tracef(ctx context.Context, format string, args ...any) {
+	l.printf(ctx, LevelTrace, format, args)
+}
+
+func (l *Logger) Debugf(ctx context.Context, format string, args ...any) {
+	l.printf(ctx, LevelDebug, format, args)
+}
+
+func (l *Logger) Infof(ctx context.Context, format string, args ...any) {
+	l.printf(ctx, LevelInfo, format, args)
+}
+
+func (l *Logger) Warnf(ctx context.Context, format string, args ...any) {
+	l.printf(ctx, LevelWarn, format, args)
+}
+
+func (l *Logger) Errorf(ctx context.Context, format string, args ...any) {
+	l.printf(ctx, LevelError, format, args)
+}
+
+func (l *Logger) Panicf(ctx context.Context, format string, args ...any) {
+	l.printf(ctx, LevelPanic, format, args)
+}
+
+func (l *Logger) Fatalf(ctx context.Context, format string, args ...any) {
+	l.printf(ctx, LevelFatal, format, args)
+	os.Exit(1)
+}
+
+// printf is print for formatted messages. The message is only formatted once
+// the entry is admitted.
+func (l *Logger) printf(ctx context.Context, lev Level, format string, args []any) {
+	// +1 frame for admit
+	pc, ok, tail := l.admit(ctx, lev, 3)
+	if !ok && tail == nil {
+		return
+	}
+
+	msg, fields := l.format(format, args)
+	e := entry{level: lev, msg: msg, fields: fields, ctxFields: FromContext(ctx), pc: pc}
+	l.dispatch(ctx, &e, tail)
+}
+
+// format formats the message and returns the arguments as fields if they are
+// recorded. Redaction rules are applied to the arguments in the message the
+// same way they are applied to the fields.
+func (l *Logger) format(format string, args []any) (string, []Field) {
+	if !l.cfg.FormatArgs && l.redactor == nil && !strings.Contains(format, "%{") {
+		return fmt.Sprintf(format, args...), nil
+	}
+
+	f := getPrintfFormat(format)
+	fields := argFields(f, args, l.cfg.FormatArgs)
+	if l.redactor != nil {
+		args = l.redactor.redactArgs(f, args, l.group)
+	}
+	return fmt.Sprintf(f.text, args...), fields
+}
+
+func getPrintfFormat(format string) *printfFormat {
+	printfFormatsLock.RLock()
+	f, ok := printfFormats[format]
+	printfFormatsLock.RUnlock()
+	if ok {
+		return f
+	}
+
+	owned := strings.Clone(format)
+	f = parsePrintfFormat(owned)
+	printfFormatsLock.Lock()
+	if len(printfFormats) < maxPrintfFormats {
+		printfFormats[owned] = f
+	}
+	printfFormatsLock.Unlock()
+	return f
+}
+
+// argFields returns the arguments consumed by verbs as fields. Named arguments
+// are always included, unnamed ones only if requested.
+func argFields(f *printfFormat, args []any, unnamed bool) []Field {
+	var fields []Field
+	for i, arg := range args {
+		var name string
+		if i < len(f.names) {
+			name = f.names[i]
+		}
+		switch {
+		case name == "-":
+			// Width or precision argument
+		case name != "":
+			fields = append(fields, Any(name, arg))
+		case unnamed:
+			fields = append(fields, Any("arg"+strconv.Itoa(i), arg))
+		}
+	}
+	return fields
+}
+
+// parsePrintfFormat parses the format and strips argument names from it.
+// Explicit argument indexes are not supported and are treated as if arguments
+// were consumed in order.
+func parsePrintfFormat(format string) *printfFormat {
+	var f printfFormat
+	var text strings.Builder
+	text.Grow(len(format))
+
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		text.WriteByte(c)
+		if c != '%' {
+			continue
+		}
+		i++
+		if i == len(format) {
+			break
+		}
+		if format[i] == '%' {
+			text.WriteByte('%')
+			continue
+		}
+
+		var name string
+		if format[i] == '{' {
+			if end := strings.IndexByte(format[i:], '}'); end > 0 {
+				name = format[i+1 : i+end]
+				i += end + 1
+			}
+		}
+
+		// Flags, width, precision and argument indexes up to the verb
+		for ; i < len(format); i++ {
+			c := format[i]
+			if c == '*' {
+				// Width or precision taken from an argument
+				f.names = append(f.names, "-")
+			}
+			if !strings.ContainsRune("+-# 0123456789.*[]", rune(c)) {
+				break
+			}
+			text.WriteByte(c)
+		}
+		if i < len(format) {
+			text.WriteByte(format[i])
+			f.names = append(f.names, name)
+		}
+	}
+
+	f.text = text.String()
+	return &f
+}
@@ -0,0 +1,90 @@
+package riff
+
+import (
+	"bytes"
+)
+
+// Layout is the way entries are laid out in the output.
+type Layout int
+
+const (
+	// LayoutLine prints every entry on a single line.
+	LayoutLine Layout = iota
+	// LayoutTable prints every entry on a single line and pads fields so that
+	// the fields of consecutive entries with the same keys line up.
+	LayoutTable
+	// LayoutMultiline prints the time, level and message on the first line and
+	// every field on its own indented line as key: value. Lines of multi-line
+	// values and stack traces are indented under their field.
+	LayoutMultiline
+)
+
+const (
+	fieldIndent = "  "
+	valueIndent = "    "
+)
+
+// printSeparator prints what separates a field from the preceding part of the
+// entry. Pad tells whether anything precedes the field on the line.
+func (l *Logger) printSeparator(buf *[]byte, pad bool) {
+	switch {
+	case l.cfg.Layout == LayoutMultiline:
+		*buf = append(*buf, '\n')
+		*buf = append(*buf, fieldIndent...)
+	case pad:
+		*buf = append(*buf, ' ')
+	}
+}
+
+// printAssign prints what separates a key from its value.
+func (l *Logger) printAssign(buf *[]byte) {
+	if l.cfg.Layout == LayoutMultiline {
+		*buf = append(*buf, ':', ' ')
+	} else {
+		*buf = append(*buf, '=')
+	}
+}
+
+// finishValue truncates the value that starts at the given offset and indents
+// its lines in the multi-line layout.
+func (l *Logger) finishValue(buf *[]byte, start int) {
+	truncate(buf, start, l.cfg.MaxValueLength)
+	if l.cfg.Layout == LayoutMultiline {
+		indentLines(buf, start, valueIndent)
+	}
+}
+
+// printStackKey prints the line that precedes a stack trace in the multi-line
+// layout.
+func (l *Logger) printStackKey(buf *[]byte, lev Level) {
+	*buf = append(*buf, fieldIndent...)
+	l.printKey(buf, lev, "", "stack")
+	*buf = append(*buf, ':', '\n')
+	*buf = append(*buf, valueIndent...)
+}
+
+// indentLines inserts the indent, which must consist of spaces, after every
+// line break past the given offset.
+func indentLines(buf *[]byte, start int, indent string) {
+	n := bytes.Count((*buf)[start:], []byte{'\n'})
+	if n == 0 {
+		return
+	}
+
+	end := len(*buf)
+	for range n * len(indent) {
+		*buf = append(*buf, ' ')
+	}
+	// Move the bytes to the right starting from the end, leaving room for the
+	// indent after every line break
+	b := *buf
+	w := len(b)
+	for r := end - 1; r >= start; r-- {
+		if b[r] == '\n' {
+			w -= len(indent)
+			copy(b[w:], indent)
+		}
+		w--
+		b[w] = b[r]
+	}
+}
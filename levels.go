@@ -0,0 +1,393 @@
+package riff
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// LevelLabel is the way levels are labeled in the output.
+type LevelLabel int
+
+const (
+	// LevelLabelShort labels levels with 4 uppercase letters, such as INFO.
+	LevelLabelShort LevelLabel = iota
+	// LevelLabelFull labels levels with their full lowercase names, such as
+	// info, padded to 5 characters.
+	LevelLabelFull
+	// LevelLabelLetter labels levels with a single letter, such as I.
+	LevelLabelLetter
+	// LevelLabelEmoji labels levels with an emoji.
+	LevelLabelEmoji
+)
+
+// LevelOptions describes a custom level.
+type LevelOptions struct {
+	// Name is the lowercase name of the level that is used by ParseLevel and
+	// String, such as "notice". It is required.
+	Name string
+	// Short is the 4 character label, the first 4 letters of the name in upper
+	// case by default.
+	Short string
+	// Letter is the single letter label, the first letter of the name in upper
+	// case by default.
+	Letter string
+	// Emoji is the emoji label.
+	Emoji string
+	// Style is the default color of the level.
+	Style Style
+}
+
+type levelDesc struct {
+	name   string
+	short  string
+	full   string // Name padded to 5 characters
+	letter string
+	emoji  string
+	style  Style
+}
+
+func (d *levelDesc) label(l LevelLabel) string {
+	switch l {
+	case LevelLabelFull:
+		return d.full
+	case LevelLabelLetter:
+		return d.letter
+	case LevelLabelEmoji:
+		return d.emoji
+	default:
+		return d.short
+	}
+}
+
+// Built-in levels in the order of severity.
+var builtinLevels = [...]levelDesc{
+	{name: "trace", short: "TRAC", full: "trace", letter: "T", emoji: "🔬", style: colorOffWhite},
+	{name: "debug", short: "DEBU", full: "debug", letter: "D", emoji: "🐛", style: colorOffWhite},
+	{name: "info", short: "INFO", full: "info ", letter: "I", emoji: "💬", style: colorCyan},
+	{name: "warn", short: "WARN", full: "warn ", letter: "W", emoji: "🔶", style: colorYellow},
+	{name: "error", short: "ERRO", full: "error", letter: "E", emoji: "❌", style: colorRed},
+	{name: "panic", short: "PANI", full: "panic", letter: "P", emoji: "💥", style: colorRedBg + colorWhite},
+	{name: "fatal", short: "FATA", full: "fatal", letter: "F", emoji: "💀", style: colorRedBg + colorWhite},
+}
+
+// Custom levels are replaced as a whole on registration, so that they can be
+// read without locking.
+var (
+	customLevels     atomic.Pointer[map[Level]*levelDesc]
+	customLevelsLock sync.Mutex
+)
+
+// RegisterLevel registers a custom level with the given severity, such as
+// LevelInfo+5 for a level between Info and Warn. Levels are meant to be
+// registered during initialization, before they are used.
+func RegisterLevel(lev Level, opts LevelOptions) error {
+	name := strings.ToLower(strings.TrimSpace(opts.Name))
+	if name == "" {
+		return fmt.Errorf("riff: level %d has no name", lev)
+	}
+	if strings.ContainsAny(name, ",= ") {
+		return fmt.Errorf("riff: invalid level name %q", name)
+	}
+
+	customLevelsLock.Lock()
+	defer customLevelsLock.Unlock()
+
+	if levelDescOf(lev) != nil {
+		return fmt.Errorf("riff: level %d is already registered", lev)
+	}
+	if _, err := ParseLevel(name); err == nil {
+		return fmt.Errorf("riff: level %q is already registered", name)
+	}
+
+	d := &levelDesc{
+		name:   name,
+		short:  opts.Short,
+		full:   fmt.Sprintf("%-5s", name),
+		letter: opts.Letter,
+		emoji:  opts.Emoji,
+		style:  opts.Style,
+	}
+	if d.short == "" {
+		d.short = fmt.Sprintf("%-4.4s", strings.ToUpper(name))
+	}
+	if d.letter == "" {
+		d.letter = strings.ToUpper(name[:1])
+	}
+	if d.emoji == "" {
+		d.emoji = "🔹"
+	}
+
+	levels := map[Level]*levelDesc{lev: d}
+	if old := customLevels.Load(); old != nil {
+		for l, d := range *old {
+			levels[l] = d
+		}
+	}
+	customLevels.Store(&levels)
+	return nil
+}
+
+// levelDescOf returns the description of a built-in or registered level, or
+// nil if the level is unknown.
+func levelDescOf(lev Level) *levelDesc {
+	if lev >= LevelTrace && lev <= LevelFatal && lev%10 == 0 {
+		return &builtinLevels[lev/10]
+	}
+	if levels := customLevels.Load(); levels != nil {
+		return (*levels)[lev]
+	}
+	return nil
+}
+
+// levelLabel returns the label of the level in the given style. Unknown levels
+// are labeled the same way String names them.
+func levelLabel(lev Level, l LevelLabel) string {
+	if d := levelDescOf(lev); d != nil {
+		return d.label(l)
+	}
+	return lev.String()
+}
+
+// ParseLevel parses a level name, such as "info" or "warn".
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(s) {
+	case "trace":
+		return LevelTrace, nil
+	case "debug":
+		return LevelDebug, nil
+	case "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	case "panic":
+		return LevelPanic, nil
+	case "fatal":
+		return LevelFatal, nil
+	}
+	if levels := customLevels.Load(); levels != nil {
+		name := strings.ToLower(s)
+		for lev, d := range *levels {
+			if d.name == name {
+				return lev, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("riff: unknown level %q", s)
+}
+
+// String returns the lowercase name of the level.
+func (lev Level) String() string {
+	if d := levelDescOf(lev); d != nil {
+		return d.name
+	}
+	return "level(" + strconv.Itoa(int(lev)) + ")"
+}
+
+// LevelSpec configures levels per component or package. It is parsed from a
+// comma separated list of rules, such as:
+//
+//	info,db=debug,http.client=warn,github.com/acme/*=error
+//
+// A rule without a pattern sets the default level. Patterns are matched
+// against package paths of the call sites, patterns without a slash are also
+// matched against names of named loggers, so that both db and main can be
+// targeted. A pattern matches the name or package itself and everything nested
+// in it, a trailing asterisk matches any suffix. The longest matching pattern
+// wins.
+//
+// LevelSpec implements flag.Value, so it can be configured with a flag.
+// Loggers keep a copy of the spec, so changing the spec with Set doesn't affect
+// the loggers it is already used by, pass it to SetLevelSpec again instead.
+type LevelSpec struct {
+	def      Level
+	hasDef   bool
+	rules    []levelRule
+	packages bool // Some rules need the package of the call site
+}
+
+type levelRule struct {
+	pattern string
+	prefix  bool // Pattern ends with an asterisk
+	pkg     bool // Pattern is a package path, it doesn't match names
+	level   Level
+}
+
+// ParseLevelSpec parses the level spec.
+func ParseLevelSpec(s string) (*LevelSpec, error) {
+	var spec LevelSpec
+	if err := spec.Set(s); err != nil {
+		return nil, err
+	}
+	return &spec, nil
+}
+
+// Set parses the level spec and replaces the current value with it.
+func (s *LevelSpec) Set(str string) error {
+	var spec LevelSpec
+	for _, part := range strings.Split(str, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		pattern, name, ok := strings.Cut(part, "=")
+		if !ok {
+			lev, err := ParseLevel(part)
+			if err != nil {
+				return err
+			}
+			spec.def, spec.hasDef = lev, true
+			continue
+		}
+
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			return fmt.Errorf("riff: empty pattern in level spec rule %q", part)
+		}
+		lev, err := ParseLevel(strings.TrimSpace(name))
+		if err != nil {
+			return err
+		}
+		rule := levelRule{
+			pattern: strings.TrimSuffix(pattern, "*"),
+			prefix:  strings.HasSuffix(pattern, "*"),
+			pkg:     strings.Contains(pattern, "/"),
+			level:   lev,
+		}
+		spec.rules = append(spec.rules, rule)
+		// Any pattern can match a package
+		spec.packages = true
+	}
+
+	*s = spec
+	return nil
+}
+
+// String returns the level spec in the format it is parsed from.
+func (s *LevelSpec) String() string {
+	if s == nil {
+		return ""
+	}
+
+	var parts []string
+	if s.hasDef {
+		parts = append(parts, s.def.String())
+	}
+	for _, r := range s.rules {
+		pattern := r.pattern
+		if r.prefix {
+			pattern += "*"
+		}
+		parts = append(parts, pattern+"="+r.level.String())
+	}
+	return strings.Join(parts, ",")
+}
+
+// resolve returns the level for the given logger name and package path.
+func (s *LevelSpec) resolve(def Level, name, pkg string) Level {
+	if s.hasDef {
+		def = s.def
+	}
+
+	best := -1
+	for _, r := range s.rules {
+		if len(r.pattern) < best {
+			continue
+		}
+		if r.matches(pkg, '/') || !r.pkg && r.matches(name, '.') {
+			def, best = r.level, len(r.pattern)
+		}
+	}
+	return def
+}
+
+func (r levelRule) matches(s string, sep byte) bool {
+	if s == "" || !strings.HasPrefix(s, r.pattern) {
+		return false
+	}
+	return r.prefix || len(s) == len(r.pattern) || s[len(r.pattern)] == sep
+}
+
+// levelRouter resolves levels according to a level spec. It is shared between
+// a logger and all the loggers derived from it.
+type levelRouter struct {
+	spec atomic.Pointer[LevelSpec]
+
+	// Resolved levels per logger name and call site
+	lock  sync.RWMutex
+	cache map[levelSite]Level
+}
+
+type levelSite struct {
+	name string
+	pc   uintptr // Only set if the spec has package rules
+}
+
+// setSpec replaces the spec with a copy of the given one, so that the spec can
+// be changed with Set while it is in use.
+func (r *levelRouter) setSpec(spec *LevelSpec) {
+	if spec != nil {
+		// Set replaces the rules rather than changing them, so a shallow copy
+		// is enough
+		c := *spec
+		spec = &c
+	}
+	r.lock.Lock()
+	r.spec.Store(spec)
+	r.cache = nil
+	r.lock.Unlock()
+}
+
+// level returns the level for the logger name and call site. The program
+// counter of the call site is only used if the spec has package rules.
+func (r *levelRouter) level(spec *LevelSpec, def Level, name string, pc uintptr) Level {
+	site := levelSite{name: name}
+	if spec.packages {
+		site.pc = pc
+	}
+
+	r.lock.RLock()
+	lev, ok := r.cache[site]
+	r.lock.RUnlock()
+	if ok {
+		return lev
+	}
+
+	lev = spec.resolve(def, name, funcPackage(site.pc))
+	r.lock.Lock()
+	// Don't cache decisions made with a spec that was already replaced
+	if r.spec.Load() == spec {
+		if r.cache == nil {
+			r.cache = make(map[levelSite]Level)
+		}
+		r.cache[site] = lev
+	}
+	r.lock.Unlock()
+	return lev
+}
+
+// funcPackage returns the package path of the function that contains the
+// program counter.
+func funcPackage(pc uintptr) string {
+	if pc == 0 {
+		return ""
+	}
+	fn := runtime.FuncForPC(pc - 1)
+	if fn == nil {
+		return ""
+	}
+
+	// Function names look like github.com/acme/pkg.(*Type).Method
+	name := fn.Name()
+	slash := strings.LastIndexByte(name, '/')
+	if dot := strings.IndexByte(name[slash+1:], '.'); dot >= 0 {
+		return name[:slash+1+dot]
+	}
+	return name
+}
@@ -8,13 +8,26 @@ import (
 	"os"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type Logger struct {
 	cfg       Config
+	name      string
+	group     string
+	levels    *levelRouter
 	timeCache func(time.Time) string
-	lock      sync.Mutex
+	sampler   *sampler
+	limiters  map[Level]*limiter
+	dedup     *deduper
+	redactor  *redactor
+	table     *table
+	dropped   *atomic.Uint64
+	lock      *sync.Mutex
+	// Set if none of the optional features are enabled, entries of a plain
+	// logger are printed by printPlain
+	plain bool
 }
 
 type Config struct {
@@ -28,18 +41,57 @@ type Config struct {
 	SortFields      bool
 	StackTraceLevel Level
 	StackTraceSkip  int
+	Sampling        Sampling
+	RateLimits      map[Level]RateLimit
+	// DedupTimeout enables collapsing of consecutive duplicate entries into a
+	// single summary entry. The summary is written when a different entry
+	// arrives or the timeout passes.
+	DedupTimeout time.Duration
+	// ContextBuffers enables buffering of entries below the configured level
+	// in contexts created with WithBuffer.
+	ContextBuffers bool
+	// ContextLevels enables levels set with WithLevel to take effect.
+	ContextLevels bool
+	// LevelSpec overrides the level for named loggers and packages. The logger
+	// keeps a copy of it, SetLevelSpec is the way to change it at runtime.
+	LevelSpec *LevelSpec
+	// FormatArgs records arguments of the formatting methods, such as Infof,
+	// as fields named arg0, arg1 and so on. Arguments named with the %{name}d
+	// extension are always recorded under their names.
+	FormatArgs bool
+	// MessageTemplates enables substitution of {key} placeholders in messages
+	// with values of the matching fields.
+	MessageTemplates bool
+	// Redaction masks, hashes or drops sensitive field values.
+	Redaction Redaction
+	// MaxMessageLength, MaxValueLength and MaxEntryBytes limit the length in
+	// bytes of messages, field values and whole lines, not including stack
+	// traces. Anything longer is cut and marked with …(truncated N bytes),
+	// the marker is not counted. Zero means no limit.
+	MaxMessageLength int
+	MaxValueLength   int
+	MaxEntryBytes    int
+	// Layout selects the way entries are laid out, LayoutLine by default.
+	Layout Layout
+	// Theme sets the colors used when Color is enabled, see DefaultTheme.
+	Theme Theme
+	// LevelLabel selects the way levels are labeled, LevelLabelShort by
+	// default.
+	LevelLabel LevelLabel
 }
 
+// Level is the severity of an entry. Built-in levels are spaced by 10, so that
+// custom levels can be registered between them with RegisterLevel.
 type Level int
 
 const (
-	LevelTrace Level = iota
-	LevelDebug       = iota
-	LevelInfo
-	LevelWarn
-	LevelError
-	LevelPanic
-	LevelFatal
+	LevelTrace Level = 0
+	LevelDebug Level = 10
+	LevelInfo  Level = 20
+	LevelWarn  Level = 30
+	LevelError Level = 40
+	LevelPanic Level = 50
+	LevelFatal Level = 60
 )
 
 const (
@@ -61,16 +113,67 @@ var (
 
 	DurationPrecision = time.Millisecond
 	TimeFormat        = time.RFC3339
+	// MaxElements limits the number of printed elements of slice and map
+	// fields, the rest are replaced with a …+N marker. Zero means no limit.
+	MaxElements = 0
 )
 
 func New(cfg Config) *Logger {
-	l := &Logger{cfg: cfg}
+	l := &Logger{
+		cfg:     cfg,
+		levels:  &levelRouter{},
+		dropped: &atomic.Uint64{},
+		lock:    &sync.Mutex{},
+	}
+	if l.cfg.LevelSpec != nil {
+		l.levels.setSpec(l.cfg.LevelSpec)
+	}
 	if l.cfg.TimePrecision > 0 {
 		l.timeCache = timeCache(l.cfg.TimeFormat, l.cfg.TimePrecision)
 	}
+	if l.cfg.Sampling.Interval > 0 {
+		l.sampler = newSampler(l.cfg.Sampling)
+	}
+	for lev, lim := range l.cfg.RateLimits {
+		if l.limiters == nil {
+			l.limiters = make(map[Level]*limiter, len(l.cfg.RateLimits))
+		}
+		r := newLimiter(lim)
+		r.resume = func() {
+			if n := r.takeSuppressed(); n > 0 {
+				l.writeSuppressed(lev, n)
+			}
+		}
+		l.limiters[lev] = r
+	}
+	if l.cfg.DedupTimeout > 0 {
+		l.dedup = newDeduper(l.cfg.DedupTimeout)
+	}
+	if l.cfg.Layout == LayoutTable {
+		l.table = &table{}
+	}
+	if len(l.cfg.Redaction.Rules) > 0 || l.cfg.Redaction.HashKey != nil {
+		l.redactor = newRedactor(l.cfg.Redaction)
+	}
+	l.plain = l.isPlain()
 	return l
 }
 
+// isPlain reports whether none of the optional features that printPlain skips
+// are enabled.
+func (l *Logger) isPlain() bool {
+	cfg := &l.cfg
+	theme := &cfg.Theme
+	return l.sampler == nil && l.limiters == nil && l.dedup == nil &&
+		l.table == nil && l.redactor == nil && l.group == "" &&
+		!cfg.ContextBuffers && !cfg.ContextLevels && !cfg.MessageTemplates &&
+		cfg.Layout == LayoutLine && cfg.MaxMessageLength <= 0 &&
+		cfg.MaxValueLength <= 0 && cfg.MaxEntryBytes <= 0 &&
+		theme.Message == "" && theme.Key == "" && theme.Highlight == nil &&
+		theme.Number == "" && theme.String == "" && theme.Error == "" &&
+		theme.Duration == ""
+}
+
 func DefaultConfig() Config {
 	return Config{
 		Level:           LevelInfo,
@@ -86,40 +189,83 @@ func DefaultConfig() Config {
 	}
 }
 
-func (l *Logger) Trace(ctx context.Context, msg string, fields ...Field) {
-	if l.cfg.Level == LevelTrace {
-		l.print(ctx, LevelTrace, msg, fields)
+// Named returns a logger for a component with the given name. Names of nested
+// components are separated with dots. The named logger shares the output and
+// all the state with its parent.
+func (l *Logger) Named(name string) *Logger {
+	c := *l
+	if c.name != "" {
+		name = c.name + "." + name
 	}
+	c.name = name
+	return &c
 }
 
-func (l *Logger) Debug(ctx context.Context, msg string, fields ...Field) {
-	if l.cfg.Level <= LevelDebug {
-		l.print(ctx, LevelDebug, msg, fields)
+// WithGroup returns a logger that groups the fields of its entries under the
+// given key, like the Group field does. Context fields are not grouped.
+func (l *Logger) WithGroup(key string) *Logger {
+	c := *l
+	if c.group != "" {
+		key = c.group + "." + key
 	}
+	c.group = key
+	c.plain = false
+	return &c
 }
 
-func (l *Logger) Info(ctx context.Context, msg string, fields ...Field) {
-	if l.cfg.Level <= LevelInfo {
-		l.print(ctx, LevelInfo, msg, fields)
+// SetLevelSpec replaces the level spec of the logger and all the loggers
+// derived from it. A nil spec restores the configured level. It is the only way
+// to change the levels at runtime, the logger keeps a copy of the spec.
+func (l *Logger) SetLevelSpec(spec *LevelSpec) {
+	l.levels.setSpec(spec)
+}
+
+// Dropped returns the number of entries dropped by sampling and rate limiting.
+func (l *Logger) Dropped() uint64 {
+	return l.dropped.Load()
+}
+
+// Enabled reports whether entries at the level are written, taking the level
+// spec and context level overrides into account. It can be used to skip
+// building expensive fields. Enabled entries can still be dropped by sampling
+// and rate limits.
+func (l *Logger) Enabled(ctx context.Context, lev Level) bool {
+	// Enabled is one frame above print
+	_, ok := l.enabled(ctx, lev, 1)
+	return ok
+}
+
+// Log writes an entry at the given level, which can be a custom one. Like
+// Fatal, it exits the program after writing an entry at the Fatal level.
+func (l *Logger) Log(ctx context.Context, lev Level, msg string, fields ...Field) {
+	l.print(ctx, lev, msg, fields)
+	if lev == LevelFatal {
+		os.Exit(1)
 	}
 }
 
+func (l *Logger) Trace(ctx context.Context, msg string, fields ...Field) {
+	l.print(ctx, LevelTrace, msg, fields)
+}
+
+func (l *Logger) Debug(ctx context.Context, msg string, fields ...Field) {
+	l.print(ctx, LevelDebug, msg, fields)
+}
+
+func (l *Logger) Info(ctx context.Context, msg string, fields ...Field) {
+	l.print(ctx, LevelInfo, msg, fields)
+}
+
 func (l *Logger) Warn(ctx context.Context, msg string, fields ...Field) {
-	if l.cfg.Level <= LevelWarn {
-		l.print(ctx, LevelWarn, msg, fields)
-	}
+	l.print(ctx, LevelWarn, msg, fields)
 }
 
 func (l *Logger) Error(ctx context.Context, msg string, fields ...Field) {
-	if l.cfg.Level <= LevelError {
-		l.print(ctx, LevelError, msg, fields)
-	}
+	l.print(ctx, LevelError, msg, fields)
 }
 
 func (l *Logger) Panic(ctx context.Context, msg string, fields ...Field) {
-	if l.cfg.Level <= LevelPanic {
-		l.print(ctx, LevelPanic, msg, fields)
-	}
+	l.print(ctx, LevelPanic, msg, fields)
 }
 
 func (l *Logger) Fatal(ctx context.Context, msg string, fields ...Field) {
@@ -131,8 +277,113 @@ func (l *Logger) Fatal(ctx context.Context, msg string, fields ...Field) {
 // Printing
 //
 
+// entry is a log entry on its way to the output.
+type entry struct {
+	level     Level
+	msg       string
+	fields    []Field
+	ctxFields []Field
+	// Fields encoded by an Event, printed ahead of the context fields
+	encoded []byte
+	// Stack trace directive of an Event
+	stack fieldKind
+	// Program counter of the call site, only set if it is needed
+	pc uintptr
+}
+
 func (l *Logger) print(ctx context.Context, lev Level, msg string, fields []Field) {
-	if lev < l.cfg.Level {
+	if l.plain && l.levels.spec.Load() == nil {
+		if lev >= l.cfg.Level {
+			l.printPlain(ctx, lev, msg, fields)
+		}
+		return
+	}
+
+	// +1 frame for admit
+	pc, ok, tail := l.admit(ctx, lev, 3)
+	if !ok && tail == nil {
+		return
+	}
+
+	e := entry{level: lev, msg: msg, fields: fields, ctxFields: FromContext(ctx), pc: pc}
+	l.dispatch(ctx, &e, tail)
+}
+
+// admit decides whether an entry at the level is written, buffered or
+// discarded. It reports whether the level is enabled at the call site, and if
+// it isn't, returns the tail buffer of the context the entry goes to. Entries
+// that are neither are discarded before anything is built. Skip is passed to
+// enabled.
+func (l *Logger) admit(ctx context.Context, lev Level, skip int) (pc uintptr, ok bool, tail *tailBuffer) {
+	pc, ok = l.enabled(ctx, lev, skip)
+	if !ok && l.cfg.ContextBuffers {
+		tail = tailFromContext(ctx)
+	}
+	return pc, ok, tail
+}
+
+// dispatch writes an admitted entry, or buffers it in the tail buffer if its
+// level is disabled.
+func (l *Logger) dispatch(ctx context.Context, e *entry, tail *tailBuffer) {
+	if tail != nil {
+		l.bufferEntry(tail, e)
+		return
+	}
+	// Skip 2 more frames for dispatch and output
+	l.output(ctx, e, l.cfg.StackTraceSkip+2)
+}
+
+// enabled reports whether the level is enabled at the call site, all the level
+// checks go through it. It also returns the program counter of the call site if
+// it is needed to process the entry. Skip is passed to callerPC.
+func (l *Logger) enabled(ctx context.Context, lev Level, skip int) (pc uintptr, ok bool) {
+	spec := l.levels.spec.Load()
+	if l.cfg.Sampling.ByCaller || spec != nil && spec.packages {
+		pc = l.callerPC(skip)
+	}
+
+	minLev := l.cfg.Level
+	if spec != nil {
+		minLev = l.levels.level(spec, minLev, l.name, pc)
+	}
+	return pc, lev >= minLev || l.contextLevelEnabled(ctx, lev)
+}
+
+// output writes an enabled entry unless it is dropped by sampling or rate
+// limits. Stack skip is the number of frames to skip from printStackTrace.
+func (l *Logger) output(ctx context.Context, e *entry, stackSkip int) {
+	if l.cfg.ContextBuffers && e.level >= tailFlushLevel {
+		l.flushTail(ctx)
+	}
+	if l.sampler != nil && !l.sampler.allow(e.level, e.msg, e.pc) {
+		l.dropped.Add(1)
+		return
+	}
+	lim := l.limiters[e.level]
+	if lim != nil && !lim.cfg.Bytes && !l.takeTokens(lim, e.level, 1) {
+		return
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	body, line := l.printEntry(buf, e)
+	l.printStackTrace(buf, e, stackSkip)
+	if lim != nil && lim.cfg.Bytes && !l.takeTokens(lim, e.level, len(*buf)) {
+		return
+	}
+	l.write(*buf, e.level, body, line, l.dedup != nil && e.hasFields())
+}
+
+// printPlain writes an entry of a plain logger. It is the shortcut of output
+// for loggers with none of the optional features enabled and entries with
+// regular fields only, the output is the same.
+func (l *Logger) printPlain(ctx context.Context, lev Level, msg string, fields []Field) {
+	var e entry
+	e.level, e.msg, e.fields, e.ctxFields = lev, msg, fields, FromContext(ctx)
+	if !regularFields(e.fields) || !regularFields(e.ctxFields) {
+		// Skip 2 more frames for printPlain and output
+		l.output(ctx, &e, l.cfg.StackTraceSkip+2)
 		return
 	}
 
@@ -141,120 +392,447 @@ func (l *Logger) print(ctx context.Context, lev Level, msg string, fields []Fiel
 
 	l.printTime(buf)
 	l.printLevel(buf, lev)
-	l.printMessage(buf, msg, len(fields) > 0)
-	l.printFields(ctx, buf, lev, fields)
+	start := len(*buf)
+	*buf = append(*buf, msg...)
+	l.padMessage(buf, start, len(*buf), len(e.fields)+len(e.ctxFields) > 0)
+	l.printFieldsPlain(buf, &e)
 	*buf = append(*buf, '\n')
-	l.printStackTrace(buf, lev)
+	if lev >= l.cfg.StackTraceLevel {
+		// printPlain takes the place of printStackTrace
+		*buf = append(*buf, stackTrace(l.cfg.StackTraceSkip)...)
+		*buf = append(*buf, '\n')
+	}
 
 	l.lock.Lock()
 	l.cfg.Output.Write(*buf)
 	l.lock.Unlock()
 }
 
+// printFieldsPlain prints the regular fields of an entry of a plain logger.
+func (l *Logger) printFieldsPlain(buf *[]byte, e *entry) {
+	var keyStyle Style
+	if l.cfg.Color {
+		keyStyle = l.levelStyle(e.level)
+	}
+	// Appending to a local copy of the buffer keeps it in registers
+	out := *buf
+
+	if !l.cfg.SortFields {
+		for i := range e.fields {
+			out = appendField(out, keyStyle, &e.fields[i], i > 0)
+		}
+		for i := range e.ctxFields {
+			out = appendField(out, keyStyle, &e.ctxFields[i], i+len(e.fields) > 0)
+		}
+		*buf = out
+		return
+	}
+
+	// Alias field groups for brevity
+	a := e.ctxFields
+	b := e.fields
+
+	// Pre-sort both slices
+	sortFields(a)
+	sortFields(b)
+
+	// Iterate over both slices and print them in sorted order
+	var i, j int
+	for i < len(a) && j < len(b) {
+		if a[i].Key < b[j].Key {
+			out = appendField(out, keyStyle, &a[i], i+j > 0)
+			i++
+		} else {
+			out = appendField(out, keyStyle, &b[j], i+j > 0)
+			j++
+		}
+	}
+
+	// Print remaining fields
+	for ; i < len(a); i++ {
+		out = appendField(out, keyStyle, &a[i], i+j > 0)
+	}
+	for ; j < len(b); j++ {
+		out = appendField(out, keyStyle, &b[j], i+j > 0)
+	}
+	*buf = out
+}
+
+// appendField appends a regular field of a plain logger with the key in the
+// given style.
+func appendField(buf []byte, keyStyle Style, f *Field, pad bool) []byte {
+	if pad {
+		buf = append(buf, ' ')
+	}
+	if keyStyle != "" {
+		buf = append(buf, keyStyle...)
+		buf = append(buf, f.Key...)
+		buf = append(buf, colorReset...)
+	} else {
+		buf = append(buf, f.Key...)
+	}
+	buf = append(buf, '=')
+	return f.ValueFn(buf)
+}
+
+// contextLevelEnabled reports whether the level is enabled by the context.
+func (l *Logger) contextLevelEnabled(ctx context.Context, lev Level) bool {
+	if !l.cfg.ContextLevels {
+		return false
+	}
+	ctxLev, ok := LevelFromContext(ctx)
+	return ok && lev >= ctxLev
+}
+
+// hasFields reports whether the entry has any fields to print.
+func (e *entry) hasFields() bool {
+	return len(e.encoded) > 0 || hasValues(e.fields) || hasValues(e.ctxFields)
+}
+
+// printEntry prints a single line entry without a stack trace. It returns the
+// offset at which the entry continues after the timestamp and the offset of
+// the line break.
+func (l *Logger) printEntry(buf *[]byte, e *entry) (body, line int) {
+	start := len(*buf)
+	l.printTime(buf)
+	body = len(*buf)
+	l.printLevel(buf, e.level)
+	l.printMessage(buf, e)
+	l.printFields(buf, e)
+	if truncate(buf, start, l.cfg.MaxEntryBytes) && l.cfg.Color {
+		// Truncation could have cut a colored key
+		*buf = append(*buf, colorReset...)
+	}
+	line = len(*buf)
+	*buf = append(*buf, '\n')
+	return body, line
+}
+
+// write writes the entry to the output. The rest of the arguments describe the
+// entry for deduplication.
+func (l *Logger) write(buf []byte, lev Level, body, line int, hasFields bool) {
+	if l.dedup != nil {
+		l.writeDeduped(buf, lev, body, line, hasFields)
+		return
+	}
+
+	l.lock.Lock()
+	l.cfg.Output.Write(buf)
+	l.lock.Unlock()
+}
+
+// takeTokens consumes tokens from the rate limiter of the level. When entries
+// are let through again after some were suppressed, a synthetic entry with the
+// number of suppressed ones is written first. If no entry follows, the limiter
+// writes it once the tokens are back.
+func (l *Logger) takeTokens(lim *limiter, lev Level, n int) bool {
+	ok, suppressed := lim.take(time.Now(), n)
+	if !ok {
+		l.dropped.Add(1)
+		return false
+	}
+	if suppressed > 0 {
+		l.writeSuppressed(lev, suppressed)
+	}
+	return true
+}
+
+// writeSuppressed writes the entry with the number of entries suppressed by
+// the rate limit of the level.
+func (l *Logger) writeSuppressed(lev Level, n int) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	body, line := l.printEntry(buf, &entry{
+		level:  lev,
+		msg:    "Rate limited, entries suppressed",
+		fields: []Field{Int("suppressed", n)},
+	})
+	l.write(*buf, lev, body, line, true)
+}
+
 func (l *Logger) printTime(buf *[]byte) {
 	if !l.cfg.Time {
 		return
 	}
 
+	styled := l.startStyle(buf, l.cfg.Theme.Time)
 	t := time.Now()
 	if l.timeCache != nil {
 		*buf = append(*buf, l.timeCache(t)...)
 	} else {
 		*buf = t.AppendFormat(*buf, l.cfg.TimeFormat)
 	}
+	l.endStyle(buf, styled)
 	*buf = append(*buf, ' ')
 }
 
 func (l *Logger) printLevel(buf *[]byte, lev Level) {
-	l.writeColorized(buf, lev, l.levelName(lev))
+	if l.cfg.Color {
+		l.writeStyled(buf, l.levelStyle(lev), l.levelName(lev))
+	} else {
+		*buf = append(*buf, l.levelName(lev)...)
+	}
 	*buf = append(*buf, ' ')
 }
 
-func (l *Logger) printMessage(buf *[]byte, msg string, needsPad bool) {
-	*buf = append(*buf, msg...)
+func (l *Logger) printMessage(buf *[]byte, e *entry) {
+	styled := l.startStyle(buf, l.cfg.Theme.Message)
+	start := len(*buf)
+	if l.cfg.MessageTemplates {
+		l.printTemplate(buf, e.msg, e.fields, e.ctxFields)
+	} else {
+		*buf = append(*buf, e.msg...)
+	}
+	truncate(buf, start, l.cfg.MaxMessageLength)
+	end := len(*buf)
+	l.endStyle(buf, styled)
+
+	if l.cfg.Layout == LayoutMultiline {
+		// Fields are printed on separate lines
+		return
+	}
+	l.padMessage(buf, start, end, l.cfg.MinMessageWidth > 0 || e.hasFields())
+}
+
+// padMessage pads the message printed between the given offsets to the
+// configured width and separates it from the fields, if there are any.
+func (l *Logger) padMessage(buf *[]byte, start, end int, hasFields bool) {
 	if l.cfg.MinMessageWidth > 0 {
+		width := displayWidth((*buf)[start:end])
 		// Pad the message to the configured width +2 spaces to separate it from
-		// the fields.
-		for range l.cfg.MinMessageWidth + 2 - len(msg) {
-			*buf = append(*buf, ' ')
+		// the fields. The buffer is padded through a local copy, so that it's
+		// kept in registers.
+		b := *buf
+		for range l.cfg.MinMessageWidth + 2 - width {
+			b = append(b, ' ')
 		}
+		*buf = b
 		// If the message is long enough not to be padded, add an extra space to
 		// separate it from the fields
-		if len(msg) > l.cfg.MinMessageWidth {
+		if width > l.cfg.MinMessageWidth {
 			// Separate message from fields with 2 spaces
 			*buf = append(*buf, ' ', ' ')
 		}
-	} else if needsPad {
+	} else if hasFields {
 		// Separate message from fields with 2 spaces
 		*buf = append(*buf, ' ', ' ')
 	}
 }
 
-func (l *Logger) printFields(ctx context.Context, buf *[]byte, lev Level, fields []Field) {
+func (l *Logger) printFields(buf *[]byte, e *entry) {
+	var row *tableRow
+	if l.table != nil {
+		row = &tableRow{}
+	}
+	start := len(*buf)
+
+	// Fields encoded by an Event go first as they can't be sorted
+	*buf = append(*buf, e.encoded...)
+	pad := len(e.encoded) > 0
+	if pad {
+		row.add("", len(*buf))
+	}
+
 	if l.cfg.SortFields {
-		l.printFieldsSorted(ctx, buf, lev, fields)
+		l.printFieldsSorted(buf, e, pad, row)
 	} else {
-		l.printFieldsUnsorted(ctx, buf, lev, fields)
+		l.printFieldsUnsorted(buf, e, pad, row)
+	}
+	if row != nil {
+		l.table.align(buf, start, row)
 	}
 }
 
-func (l *Logger) printFieldsUnsorted(ctx context.Context, buf *[]byte, lev Level, fields []Field) {
-	for i, f := range fields {
-		l.printField(buf, lev, f, i > 0)
+func (l *Logger) printFieldsUnsorted(buf *[]byte, e *entry, pad bool, row *tableRow) {
+	for i := range e.fields {
+		pad = l.printColumn(buf, e.level, l.group, &e.fields[i], pad, row) || pad
 	}
-	for i, f := range FromContext(ctx) {
-		l.printField(buf, lev, f, i+len(fields) > 0)
+	for i := range e.ctxFields {
+		pad = l.printColumn(buf, e.level, "", &e.ctxFields[i], pad, row) || pad
 	}
 }
 
-func (l *Logger) printFieldsSorted(ctx context.Context, buf *[]byte, lev Level, fields []Field) {
+func (l *Logger) printFieldsSorted(buf *[]byte, e *entry, pad bool, row *tableRow) {
 	// Alias field groups for brevity
-	a := FromContext(ctx)
-	b := fields
+	a := e.ctxFields
+	b := e.fields
 
 	// Pre-sort both slices
 	sortFields(a)
 	sortFields(b)
 
+	// Fields of a logger with a group are sorted as a single group field
+	if l.group != "" {
+		var i int
+		for ; i < len(a) && a[i].Key < l.group; i++ {
+			pad = l.printColumn(buf, e.level, "", &a[i], pad, row) || pad
+		}
+		for j := range b {
+			pad = l.printColumn(buf, e.level, l.group, &b[j], pad, row) || pad
+		}
+		for ; i < len(a); i++ {
+			pad = l.printColumn(buf, e.level, "", &a[i], pad, row) || pad
+		}
+		return
+	}
+
 	// Iterate over both slices and print them in sorted order
 	var i, j int
 	for i < len(a) && j < len(b) {
 		if a[i].Key < b[j].Key {
-			l.printField(buf, lev, a[i], i+j > 0)
+			pad = l.printColumn(buf, e.level, "", &a[i], pad, row) || pad
 			i++
 		} else {
-			l.printField(buf, lev, b[j], i+j > 0)
+			pad = l.printColumn(buf, e.level, "", &b[j], pad, row) || pad
 			j++
 		}
 	}
 
 	// Print remaining fields
-	for i < len(a) {
-		l.printField(buf, lev, a[i], i+j > 0)
-		i++
+	for ; i < len(a); i++ {
+		pad = l.printColumn(buf, e.level, "", &a[i], pad, row) || pad
 	}
-	for j < len(b) {
-		l.printField(buf, lev, b[j], i+j > 0)
-		j++
+	for ; j < len(b); j++ {
+		pad = l.printColumn(buf, e.level, "", &b[j], pad, row) || pad
 	}
 }
 
-func (l *Logger) printField(buf *[]byte, lev Level, f Field, pad bool) {
-	if pad {
-		*buf = append(*buf, ' ')
+// printField prints a single field and reports whether anything was printed.
+// Special fields, such as stack trace directives, are skipped.
+func (l *Logger) printField(buf *[]byte, lev Level, f Field, pad bool) bool {
+	return l.printFieldPrefixed(buf, lev, "", &f, pad)
+}
+
+// printFieldPrefixed prints a field with the key prefixed with the given group
+// key. Fields of a group are printed one by one with the group key appended to
+// the prefix.
+func (l *Logger) printFieldPrefixed(buf *[]byte, lev Level, prefix string, f *Field, pad bool) bool {
+	switch f.kind() {
+	case kindValue, kindSecret:
+	case kindGroup:
+		return l.printGroup(buf, lev, prefix, f, pad)
+	default:
+		return false
+	}
+	if f.kind() == kindSecret || l.redactor != nil {
+		return l.printRedacted(buf, lev, prefix, f, pad)
 	}
-	l.writeColorized(buf, lev, f.Key)
-	*buf = append(*buf, '=')
+
+	l.printSeparator(buf, pad)
+	l.printKey(buf, lev, prefix, f.Key)
+	l.printAssign(buf)
+	styled := l.startStyle(buf, l.valueStyle(f.typ()))
+	start := len(*buf)
 	*buf = f.ValueFn(*buf)
+	if l.cfg.MaxValueLength > 0 || l.cfg.Layout == LayoutMultiline {
+		l.finishValue(buf, start)
+	}
+	l.endStyle(buf, styled)
+	return true
 }
 
-func (l *Logger) printStackTrace(buf *[]byte, lev Level) {
-	if lev >= l.cfg.StackTraceLevel {
-		// Print stack trace but skip the first 4 frames which are part of the
-		// logger itself.
-		*buf = append(*buf, stackTrace(l.cfg.StackTraceSkip)...)
-		*buf = append(*buf, '\n')
+// printGroup prints the fields of a group field. It is kept apart from
+// printFieldPrefixed to keep the latter cheap for regular fields.
+func (l *Logger) printGroup(buf *[]byte, lev Level, prefix string, f *Field, pad bool) bool {
+	if prefix != "" {
+		prefix += "." + f.Key
+	} else {
+		prefix = f.Key
+	}
+	group := f.ext.group
+	if l.cfg.SortFields {
+		sortFields(group)
+	}
+
+	var printed bool
+	for i := range group {
+		if l.printFieldPrefixed(buf, lev, prefix, &group[i], pad || printed) {
+			printed = true
+		}
 	}
+	return printed
+}
+
+// printKey prints a colorized field key with an optional group prefix.
+func (l *Logger) printKey(buf *[]byte, lev Level, prefix, key string) {
+	if !l.cfg.Color && prefix == "" {
+		*buf = append(*buf, key...)
+		return
+	}
+
+	// Resolving the style takes map lookups, skip it if colors are disabled
+	var styled bool
+	if l.cfg.Color {
+		styled = l.startStyle(buf, l.keyStyle(lev, key))
+	}
+	if prefix != "" {
+		*buf = append(*buf, prefix...)
+		*buf = append(*buf, '.')
+	}
+	*buf = append(*buf, key...)
+	l.endStyle(buf, styled)
+}
+
+func (l *Logger) printStackTrace(buf *[]byte, e *entry, skip int) {
+	kind := stackDirective(e.ctxFields, kindValue)
+	kind = stackDirective(e.fields, kind)
+	if e.stack != kindValue {
+		kind = e.stack
+	}
+
+	start := len(*buf)
+	if l.cfg.Layout == LayoutMultiline {
+		l.printStackKey(buf, e.level)
+	}
+	switch {
+	case kind == kindStackAll:
+		*buf = appendAllStacks(*buf)
+	case kind == kindStack, kind == kindValue && e.level >= l.cfg.StackTraceLevel:
+		// Print stack trace but skip the frames which are part of the logger
+		// itself.
+		*buf = append(*buf, stackTrace(skip)...)
+	default:
+		*buf = (*buf)[:start]
+		return
+	}
+	if l.cfg.Layout == LayoutMultiline {
+		// Trailing line break of the stack trace is not indented
+		*buf = bytes.TrimSuffix(*buf, []byte{'\n'})
+		indentLines(buf, start, valueIndent)
+	}
+	*buf = append(*buf, '\n')
+}
+
+// hasValues reports whether any of the fields is going to be printed.
+func hasValues(fields []Field) bool {
+	for i := range fields {
+		if f := &fields[i]; f.isValue() || f.kind() == kindGroup && hasValues(f.ext.group) {
+			return true
+		}
+	}
+	return false
+}
+
+// regularFields reports whether all the fields are regular key-value pairs.
+func regularFields(fields []Field) bool {
+	for i := range fields {
+		if fields[i].kind() != kindValue {
+			return false
+		}
+	}
+	return true
+}
+
+// stackDirective returns the kind of the last stack trace directive among the
+// fields, or def if there is none.
+func stackDirective(fields []Field, def fieldKind) fieldKind {
+	for i := range fields {
+		if fields[i].isDirective() {
+			def = fields[i].kind()
+		}
+	}
+	return def
 }
 
 func sortFields(f []Field) {
@@ -277,49 +855,21 @@ func insertionSort(f []Field) {
 // Helpers
 //
 
-func (l *Logger) writeColorized(buf *[]byte, lev Level, str string) {
-	if !l.cfg.Color {
-		*buf = append(*buf, str...)
-		return
-	}
-
-	switch lev {
-	case LevelTrace, LevelDebug:
-		*buf = append(*buf, colorOffWhite...)
-	case LevelInfo:
-		*buf = append(*buf, colorCyan...)
-	case LevelWarn:
-		*buf = append(*buf, colorYellow...)
-	case LevelError:
-		*buf = append(*buf, colorRed...)
-	case LevelPanic, LevelFatal:
-		*buf = append(*buf, colorRedBg...)
-		*buf = append(*buf, colorWhite...)
-	}
-	*buf = append(*buf, str...)
-	*buf = append(*buf, colorReset...)
+// levelName returns the label of the level in the configured style.
+func (l *Logger) levelName(lev Level) string {
+	return levelLabel(lev, l.cfg.LevelLabel)
 }
 
-// levelName returns level label that is consistently 4 characters long.
-func (l *Logger) levelName(lev Level) string {
-	switch lev {
-	case LevelTrace:
-		return "TRAC"
-	case LevelDebug:
-		return "DEBU"
-	case LevelInfo:
-		return "INFO"
-	case LevelWarn:
-		return "WARN"
-	case LevelError:
-		return "ERRO"
-	case LevelPanic:
-		return "PANI"
-	case LevelFatal:
-		return "FATA"
-	default:
-		panic("unreachable")
-	}
+// callerPC returns the program counter of the logging call site. StackTraceSkip
+// counts the frames from print, the skip argument adjusts it for the depth
+// callerPC is called at: 1 if called from print, 2 if called from a function
+// called by print, and so on.
+func (l *Logger) callerPC(skip int) uintptr {
+	var pc [1]uintptr
+	// +1 frame to skip for runtime.Callers, callerPC itself takes the place of
+	// printStackTrace
+	runtime.Callers(l.cfg.StackTraceSkip+skip, pc[:])
+	return pc[0]
 }
 
 func stackTrace(skip int) string {
@@ -340,6 +890,18 @@ func stackTrace(skip int) string {
 	return buf.String()
 }
 
+// appendAllStacks appends stack traces of all goroutines to the buffer.
+func appendAllStacks(buf []byte) []byte {
+	stack := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(stack, true)
+		if n < len(stack) {
+			return append(buf, stack[:n]...)
+		}
+		stack = make([]byte, 2*len(stack))
+	}
+}
+
 func timeCache(format string, precision time.Duration) func(time.Time) string {
 	var lastTime time.Time
 	var lastTimeStr string
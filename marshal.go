@@ -0,0 +1,273 @@
+package riff
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ObjectMarshaler is implemented by types that know how to write themselves
+// to the log as a set of key-value pairs.
+type ObjectMarshaler interface {
+	LogObject(enc ObjectEncoder)
+}
+
+// ArrayMarshaler is implemented by types that know how to write themselves to
+// the log as a list of values.
+type ArrayMarshaler interface {
+	LogArray(enc ArrayEncoder)
+}
+
+// ObjectEncoder is used by ObjectMarshaler implementations to add key-value
+// pairs to the object being written.
+type ObjectEncoder interface {
+	AddStr(key, value string)
+	AddInt(key string, value int)
+	AddInt64(key string, value int64)
+	AddUint64(key string, value uint64)
+	AddFloat64(key string, value float64)
+	AddBool(key string, value bool)
+	AddDuration(key string, value time.Duration)
+	AddTime(key string, value time.Time)
+	AddObject(key string, value ObjectMarshaler)
+	AddArray(key string, value ArrayMarshaler)
+	AddAny(key string, value any)
+}
+
+// ArrayEncoder is used by ArrayMarshaler implementations to add values to the
+// list being written.
+type ArrayEncoder interface {
+	AppendStr(value string)
+	AppendInt(value int)
+	AppendInt64(value int64)
+	AppendUint64(value uint64)
+	AppendFloat64(value float64)
+	AppendBool(value bool)
+	AppendDuration(value time.Duration)
+	AppendTime(value time.Time)
+	AppendObject(value ObjectMarshaler)
+	AppendArray(value ArrayMarshaler)
+	AppendAny(value any)
+}
+
+// Object returns a field with the given key and a value that is written by its
+// LogObject method. Objects are printed as {key=value,key=value}.
+func Object(key string, value ObjectMarshaler) Field {
+	return field(key, func(b []byte) []byte {
+		return appendObject(b, value)
+	})
+}
+
+// Array returns a field with the given key and a value that is written by its
+// LogArray method. Arrays are printed as [value,value].
+func Array(key string, value ArrayMarshaler) Field {
+	return field(key, func(b []byte) []byte {
+		return appendArray(b, value)
+	})
+}
+
+// textEncoder implements both ObjectEncoder and ArrayEncoder for the text
+// format. Nested objects and arrays are written by the same encoder.
+type textEncoder struct {
+	buf []byte
+	n   int // Number of elements written at the current level
+}
+
+var textEncoderPool = sync.Pool{
+	New: func() any {
+		return &textEncoder{}
+	},
+}
+
+func appendObject(b []byte, value ObjectMarshaler) (res []byte) {
+	if value == nil {
+		return append(b, "<nil>"...)
+	}
+	defer recoverValue(b, value, &res)
+
+	enc, _ := textEncoderPool.Get().(*textEncoder)
+	enc.buf = b
+	enc.n = 0
+	enc.object(value)
+	res = enc.buf
+	*enc = textEncoder{}
+	textEncoderPool.Put(enc)
+	return res
+}
+
+func appendArray(b []byte, value ArrayMarshaler) (res []byte) {
+	if value == nil {
+		return append(b, "<nil>"...)
+	}
+	defer recoverValue(b, value, &res)
+
+	enc, _ := textEncoderPool.Get().(*textEncoder)
+	enc.buf = b
+	enc.n = 0
+	enc.array(value)
+	res = enc.buf
+	*enc = textEncoder{}
+	textEncoderPool.Put(enc)
+	return res
+}
+
+func (enc *textEncoder) object(value ObjectMarshaler) {
+	if value == nil {
+		enc.buf = append(enc.buf, "<nil>"...)
+		return
+	}
+	n := enc.n
+	enc.n = 0
+	enc.buf = append(enc.buf, '{')
+	value.LogObject(enc)
+	enc.buf = append(enc.buf, '}')
+	enc.n = n
+}
+
+func (enc *textEncoder) array(value ArrayMarshaler) {
+	if value == nil {
+		enc.buf = append(enc.buf, "<nil>"...)
+		return
+	}
+	n := enc.n
+	enc.n = 0
+	enc.buf = append(enc.buf, '[')
+	value.LogArray(enc)
+	enc.buf = append(enc.buf, ']')
+	enc.n = n
+}
+
+// key writes the separator and the key of the next object element.
+func (enc *textEncoder) key(key string) {
+	enc.elem()
+	enc.buf = append(enc.buf, key...)
+	enc.buf = append(enc.buf, '=')
+}
+
+// elem writes the separator of the next element.
+func (enc *textEncoder) elem() {
+	if enc.n > 0 {
+		enc.buf = append(enc.buf, ',')
+	}
+	enc.n++
+}
+
+func (enc *textEncoder) AddStr(key, value string) {
+	enc.key(key)
+	enc.buf = append(enc.buf, value...)
+}
+
+func (enc *textEncoder) AddInt(key string, value int) {
+	enc.AddInt64(key, int64(value))
+}
+
+func (enc *textEncoder) AddInt64(key string, value int64) {
+	enc.key(key)
+	enc.buf = strconv.AppendInt(enc.buf, value, 10)
+}
+
+func (enc *textEncoder) AddUint64(key string, value uint64) {
+	enc.key(key)
+	enc.buf = strconv.AppendUint(enc.buf, value, 10)
+}
+
+func (enc *textEncoder) AddFloat64(key string, value float64) {
+	enc.key(key)
+	enc.buf = strconv.AppendFloat(enc.buf, value, 'f', -1, 64)
+}
+
+func (enc *textEncoder) AddBool(key string, value bool) {
+	enc.key(key)
+	enc.buf = strconv.AppendBool(enc.buf, value)
+}
+
+func (enc *textEncoder) AddDuration(key string, value time.Duration) {
+	enc.key(key)
+	enc.buf = append(enc.buf, value.Truncate(DurationPrecision).String()...)
+}
+
+func (enc *textEncoder) AddTime(key string, value time.Time) {
+	enc.key(key)
+	enc.buf = value.AppendFormat(enc.buf, TimeFormat)
+}
+
+func (enc *textEncoder) AddObject(key string, value ObjectMarshaler) {
+	enc.key(key)
+	enc.object(value)
+}
+
+func (enc *textEncoder) AddArray(key string, value ArrayMarshaler) {
+	enc.key(key)
+	enc.array(value)
+}
+
+func (enc *textEncoder) AddAny(key string, value any) {
+	enc.key(key)
+	enc.any(value)
+}
+
+func (enc *textEncoder) AppendStr(value string) {
+	enc.elem()
+	enc.buf = append(enc.buf, value...)
+}
+
+func (enc *textEncoder) AppendInt(value int) {
+	enc.AppendInt64(int64(value))
+}
+
+func (enc *textEncoder) AppendInt64(value int64) {
+	enc.elem()
+	enc.buf = strconv.AppendInt(enc.buf, value, 10)
+}
+
+func (enc *textEncoder) AppendUint64(value uint64) {
+	enc.elem()
+	enc.buf = strconv.AppendUint(enc.buf, value, 10)
+}
+
+func (enc *textEncoder) AppendFloat64(value float64) {
+	enc.elem()
+	enc.buf = strconv.AppendFloat(enc.buf, value, 'f', -1, 64)
+}
+
+func (enc *textEncoder) AppendBool(value bool) {
+	enc.elem()
+	enc.buf = strconv.AppendBool(enc.buf, value)
+}
+
+func (enc *textEncoder) AppendDuration(value time.Duration) {
+	enc.elem()
+	enc.buf = append(enc.buf, value.Truncate(DurationPrecision).String()...)
+}
+
+func (enc *textEncoder) AppendTime(value time.Time) {
+	enc.elem()
+	enc.buf = value.AppendFormat(enc.buf, TimeFormat)
+}
+
+func (enc *textEncoder) AppendObject(value ObjectMarshaler) {
+	enc.elem()
+	enc.object(value)
+}
+
+func (enc *textEncoder) AppendArray(value ArrayMarshaler) {
+	enc.elem()
+	enc.array(value)
+}
+
+func (enc *textEncoder) AppendAny(value any) {
+	enc.elem()
+	enc.any(value)
+}
+
+// any writes the value the same way Any does, marshalers are written in place.
+func (enc *textEncoder) any(value any) {
+	switch v := value.(type) {
+	case ObjectMarshaler:
+		enc.object(v)
+	case ArrayMarshaler:
+		enc.array(v)
+	default:
+		enc.buf = Any("", value).ValueFn(enc.buf)
+	}
+}
@@ -2,43 +2,113 @@ package log
 
 import (
 	"context"
+	"fmt"
+	"sync/atomic"
 	"time"
 
 	"github.com/localhots/riff"
 )
 
-var logger *riff.Logger
+var logger atomic.Pointer[riff.Logger]
+
+func init() {
+	logger.Store(riff.New(riff.DefaultConfig()))
+}
 
 func Setup(cfg riff.Config) {
-	logger = riff.New(cfg)
+	logger.Store(riff.New(cfg))
+}
+
+// Replace replaces the global logger and returns a function that restores the
+// previous one, which is handy in tests.
+func Replace(l *riff.Logger) (restore func()) {
+	prev := logger.Swap(l)
+	return func() {
+		logger.Store(prev)
+	}
+}
+
+// L returns the global logger.
+func L() *riff.Logger {
+	return logger.Load()
+}
+
+func Named(name string) *riff.Logger {
+	return L().Named(name)
+}
+
+func SetLevelSpec(spec *riff.LevelSpec) {
+	L().SetLevelSpec(spec)
 }
 
 func Trace(msg string, fields ...riff.Field) {
-	logger.Trace(context.Background(), msg, fields...)
+	L().Trace(context.Background(), msg, fields...)
 }
 
 func Debug(msg string, fields ...riff.Field) {
-	logger.Debug(context.Background(), msg, fields...)
+	L().Debug(context.Background(), msg, fields...)
 }
 
 func Info(msg string, fields ...riff.Field) {
-	logger.Info(context.Background(), msg, fields...)
+	L().Info(context.Background(), msg, fields...)
 }
 
 func Warn(msg string, fields ...riff.Field) {
-	logger.Warn(context.Background(), msg, fields...)
+	L().Warn(context.Background(), msg, fields...)
 }
 
 func Error(msg string, fields ...riff.Field) {
-	logger.Error(context.Background(), msg, fields...)
+	L().Error(context.Background(), msg, fields...)
 }
 
 func Panic(msg string, fields ...riff.Field) {
-	logger.Panic(context.Background(), msg, fields...)
+	L().Panic(context.Background(), msg, fields...)
 }
 
 func Fatal(msg string, fields ...riff.Field) {
-	logger.Fatal(context.Background(), msg, fields...)
+	L().Fatal(context.Background(), msg, fields...)
+}
+
+func Tracef(format string, args ...any) {
+	L().Tracef(context.Background(), format, args...)
+}
+
+func Debugf(format string, args ...any) {
+	L().Debugf(context.Background(), format, args...)
+}
+
+func Infof(format string, args ...any) {
+	L().Infof(context.Background(), format, args...)
+}
+
+func Warnf(format string, args ...any) {
+	L().Warnf(context.Background(), format, args...)
+}
+
+func Errorf(format string, args ...any) {
+	L().Errorf(context.Background(), format, args...)
+}
+
+func Panicf(format string, args ...any) {
+	L().Panicf(context.Background(), format, args...)
+}
+
+func Fatalf(format string, args ...any) {
+	L().Fatalf(context.Background(), format, args...)
+}
+
+// Log logs a message at the given level, which can be a custom one. It exits
+// the program after logging at the Fatal level, like Fatal does.
+func Log(lev riff.Level, msg string, fields ...riff.Field) {
+	L().Log(context.Background(), lev, msg, fields...)
+}
+
+func Enabled(lev riff.Level) bool {
+	return L().Enabled(context.Background(), lev)
+}
+
+func Event(lev riff.Level) *riff.Event {
+	return L().Event(context.Background(), lev)
 }
 
 //
@@ -113,6 +183,86 @@ func Time(key string, value time.Time) riff.Field {
 	return riff.Time(key, value)
 }
 
+func Group(key string, fields ...riff.Field) riff.Field {
+	return riff.Group(key, fields...)
+}
+
+func Object(key string, value riff.ObjectMarshaler) riff.Field {
+	return riff.Object(key, value)
+}
+
+func Array(key string, value riff.ArrayMarshaler) riff.Field {
+	return riff.Array(key, value)
+}
+
+func Strs(key string, values []string) riff.Field {
+	return riff.Strs(key, values)
+}
+
+func Ints(key string, values []int) riff.Field {
+	return riff.Ints(key, values)
+}
+
+func Int64s(key string, values []int64) riff.Field {
+	return riff.Int64s(key, values)
+}
+
+func Floats(key string, values []float64) riff.Field {
+	return riff.Floats(key, values)
+}
+
+func Bools(key string, values []bool) riff.Field {
+	return riff.Bools(key, values)
+}
+
+func Durations(key string, values []time.Duration) riff.Field {
+	return riff.Durations(key, values)
+}
+
+func Times(key string, values []time.Time) riff.Field {
+	return riff.Times(key, values)
+}
+
+func StrMap(key string, values map[string]string) riff.Field {
+	return riff.StrMap(key, values)
+}
+
+func Struct(key string, value any) riff.Field {
+	return riff.Struct(key, value)
+}
+
+func Stringer(key string, value fmt.Stringer) riff.Field {
+	return riff.Stringer(key, value)
+}
+
+func Secret(key string, value any) riff.Field {
+	return riff.Secret(key, value)
+}
+
+func Lazy(key string, fn func() any) riff.Field {
+	return riff.Lazy(key, fn)
+}
+
+func LazyStr(key string, fn func() string) riff.Field {
+	return riff.LazyStr(key, fn)
+}
+
 func Any(key string, value any) riff.Field {
 	return riff.Any(key, value)
 }
+
+func Stack() riff.Field {
+	return riff.Stack()
+}
+
+func StackOf(scope riff.StackScope) riff.Field {
+	return riff.StackOf(scope)
+}
+
+func AllGoroutines() riff.Field {
+	return riff.AllGoroutines()
+}
+
+func NoStack() riff.Field {
+	return riff.NoStack()
+}
@@ -0,0 +1,81 @@
+package riff
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimit is a token bucket limit for a single level. Rate is the number of
+// tokens added per second and Burst is the capacity of the bucket. A token is
+// an entry, or a byte of encoded output if Bytes is set.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+	Bytes bool
+}
+
+type limiter struct {
+	cfg        RateLimit
+	lock       sync.Mutex
+	tokens     float64
+	last       time.Time
+	suppressed int
+
+	// Reports the suppressed entries once tokens are back, in case no entry
+	// follows to report them
+	timer  *time.Timer
+	resume func()
+}
+
+func newLimiter(cfg RateLimit) *limiter {
+	cfg.Burst = max(cfg.Burst, 1)
+	return &limiter{
+		cfg:    cfg,
+		tokens: float64(cfg.Burst),
+	}
+}
+
+// take consumes n tokens if there are enough of them in the bucket. It reports
+// whether the entry is allowed and, if it is, how many entries were suppressed
+// since the last allowed one.
+func (r *limiter) take(now time.Time, n int) (ok bool, suppressed int) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if !r.last.IsZero() {
+		r.tokens += now.Sub(r.last).Seconds() * r.cfg.Rate
+		r.tokens = min(r.tokens, float64(r.cfg.Burst))
+	}
+	r.last = now
+
+	// Entries larger than the bucket are let through once it is full,
+	// otherwise they would never be written.
+	need := float64(min(n, r.cfg.Burst))
+	if r.tokens < need {
+		if r.suppressed == 0 && r.cfg.Rate > 0 && r.resume != nil {
+			wait := time.Duration((need - r.tokens) / r.cfg.Rate * float64(time.Second))
+			if r.timer == nil {
+				r.timer = time.AfterFunc(wait, r.resume)
+			} else {
+				r.timer.Reset(wait)
+			}
+		}
+		r.suppressed++
+		return false, 0
+	}
+	r.tokens -= need
+
+	suppressed, r.suppressed = r.suppressed, 0
+	return true, suppressed
+}
+
+// takeSuppressed returns the number of entries suppressed since the last
+// allowed one and resets it.
+func (r *limiter) takeSuppressed() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	suppressed := r.suppressed
+	r.suppressed = 0
+	return suppressed
+}
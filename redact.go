@@ -0,0 +1,473 @@
+package riff
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Redaction configures redaction of sensitive field values. Rules are applied
+// to call fields, context fields and fields of groups before they are written,
+// and to the arguments of formatted messages. Key rules are also applied to
+// the names of struct fields and map keys within Struct, StrMap and Any values. Values
+// written by the methods of the values themselves, such as LogObject, are not
+// inspected by key rules.
+type Redaction struct {
+	// Rules are checked in order, the first matching rule is applied. Rules
+	// that only match keys take precedence over the ones with detectors, so
+	// that the values of matching keys are not even encoded.
+	Rules []RedactRule
+	// HashKey is the key of the HMAC used by RedactHash. Values hashed with the
+	// same key can be correlated without being revealed. Values of Secret
+	// fields are hashed instead of masked if the key is set. Without the key
+	// RedactHash rules mask values instead.
+	HashKey []byte
+}
+
+// RedactRule redacts values of fields with keys matching the Key pattern or
+// values matched by the Detector. If both are set, both must match. Key is a
+// case insensitive glob, such as "*password*", that is matched against both
+// the key and the full key of a field in a group, such as "http.auth".
+type RedactRule struct {
+	Key      string
+	Detector Detector
+	Action   RedactAction
+}
+
+// Detector finds sensitive data in field values.
+type Detector int
+
+const (
+	DetectNone Detector = iota
+	// DetectEmail finds email addresses.
+	DetectEmail
+	// DetectCard finds payment card numbers that pass the Luhn check.
+	DetectCard
+	// DetectJWT finds JSON Web Tokens.
+	DetectJWT
+	// DetectBearer finds bearer tokens, such as an Authorization header.
+	DetectBearer
+)
+
+// RedactAction is the way a redacted value is written.
+type RedactAction int
+
+const (
+	// RedactMask replaces the value with [REDACTED].
+	RedactMask RedactAction = iota
+	// RedactHash replaces the value with a keyed hash, such as hmac:8c1f...
+	// It falls back to RedactMask if Redaction.HashKey is not set.
+	RedactHash
+	// RedactDrop omits the field.
+	RedactDrop
+)
+
+type redactor struct {
+	keyRules   []RedactRule
+	valueRules []RedactRule
+	hashKey    []byte
+}
+
+func newRedactor(cfg Redaction) *redactor {
+	r := &redactor{hashKey: cfg.HashKey}
+	for _, rule := range cfg.Rules {
+		// Hashes computed without a key can be computed by anyone, so values
+		// that are easy to guess, such as emails, would be revealed
+		if rule.Action == RedactHash && len(cfg.HashKey) == 0 {
+			rule.Action = RedactMask
+		}
+		if rule.Detector == DetectNone {
+			r.keyRules = append(r.keyRules, rule)
+		} else {
+			r.valueRules = append(r.valueRules, rule)
+		}
+	}
+	return r
+}
+
+// Secret returns a field with the given key and a value that is never
+// written. It is masked, or hashed if Redaction.HashKey is set.
+func Secret(key string, value any) Field {
+	f := Any(key, value)
+	var ext fieldExt
+	if f.ext != nil {
+		// Descriptions can be shared, so it is copied
+		ext = *f.ext
+	}
+	ext.kind = kindSecret
+	f.ext = &ext
+	return f
+}
+
+// printRedacted prints a field with redaction rules applied and reports
+// whether anything was printed.
+func (l *Logger) printRedacted(buf *[]byte, lev Level, prefix string, f *Field, pad bool) bool {
+	start := len(*buf)
+	l.printSeparator(buf, pad)
+	l.printKey(buf, lev, prefix, f.Key)
+	l.printAssign(buf)
+	value := len(*buf)
+	if !l.appendRedacted(buf, prefix, f) {
+		*buf = (*buf)[:start]
+		return false
+	}
+	// Values are truncated after detection, so that a detector doesn't miss a
+	// value that is cut in half
+	l.finishValue(buf, value)
+	return true
+}
+
+// appendRedacted writes the value of the field with redaction rules applied.
+// It reports false if the field is dropped.
+func (l *Logger) appendRedacted(buf *[]byte, prefix string, f *Field) bool {
+	r := l.redactor
+	action, ok := RedactMask, f.kind() == kindSecret
+	if ok && r != nil && len(r.hashKey) > 0 {
+		action = RedactHash
+	}
+	if !ok && r != nil {
+		action, ok = r.keyAction(prefix, f.Key)
+	}
+	switch {
+	case ok && action == RedactDrop:
+		return false
+	case ok && action == RedactMask:
+		*buf = append(*buf, redacted...)
+		return true
+	}
+
+	start := len(*buf)
+	*buf = r.appendValue(*buf, f)
+	if !ok && r != nil {
+		action, ok = r.valueAction(prefix, f.Key, (*buf)[start:])
+	}
+	if !ok {
+		return true
+	}
+
+	switch action {
+	case RedactDrop:
+		*buf = (*buf)[:start]
+		return false
+	case RedactHash:
+		*buf = r.appendHash(*buf, start)
+	default:
+		*buf = append((*buf)[:start], redacted...)
+	}
+	return true
+}
+
+// redactedArg is a printf argument that is written as is regardless of the
+// verb.
+type redactedArg string
+
+func (a redactedArg) Format(s fmt.State, _ rune) {
+	io.WriteString(s, string(a))
+}
+
+// redactArgs returns the printf arguments with the redacted ones replaced.
+// The arguments are copied if any of them is replaced.
+func (r *redactor) redactArgs(f *printfFormat, args []any, prefix string) []any {
+	var res []any
+	for i, arg := range args {
+		var name string
+		if i < len(f.names) {
+			name = f.names[i]
+		}
+		if name == "-" {
+			// Width or precision argument
+			continue
+		}
+		value, ok := r.redactArg(prefix, name, arg)
+		if !ok {
+			continue
+		}
+		if res == nil {
+			res = slices.Clone(args)
+		}
+		res[i] = value
+	}
+	if res == nil {
+		return args
+	}
+	return res
+}
+
+// redactArg returns the replacement of a printf argument if it is redacted.
+// Key rules only apply to named arguments. Arguments of dropped fields are
+// masked, as they can't be omitted from the message.
+func (r *redactor) redactArg(prefix, name string, arg any) (any, bool) {
+	var action RedactAction
+	var ok bool
+	if name != "" {
+		action, ok = r.keyAction(prefix, name)
+	}
+	if ok && action != RedactHash {
+		return redactedArg(redacted), true
+	}
+
+	f := Any(name, arg)
+	value := r.appendValue(nil, &f)
+	if !ok {
+		action, ok = r.valueAction(prefix, name, value)
+	}
+	switch {
+	case !ok:
+		return nil, false
+	case action == RedactHash:
+		return redactedArg(r.appendHash(value, 0)), true
+	default:
+		return redactedArg(redacted), true
+	}
+}
+
+// appendValue writes the value of the field. Values of Struct, StrMap and Any
+// fields are written with key rules applied to the names of struct fields and
+// map keys.
+func (r *redactor) appendValue(b []byte, f *Field) []byte {
+	switch {
+	case r == nil || len(r.keyRules) == 0 || f.ext == nil || f.ext.value == nil:
+		return f.ValueFn(b)
+	case f.ext.reflected:
+		return appendReflect(b, reflect.ValueOf(f.ext.value), 0, nil, r)
+	default:
+		return appendAny(b, f.ext.value, r)
+	}
+}
+
+// appendHash replaces the value written after start with its keyed hash.
+func (r *redactor) appendHash(b []byte, start int) []byte {
+	mac := hmac.New(sha256.New, r.hashKey)
+	mac.Write(b[start:])
+	var sum [sha256.Size]byte
+	b = append(b[:start], "hmac:"...)
+	return hex.AppendEncode(b, mac.Sum(sum[:0])[:8])
+}
+
+// keyAction returns the action of the first key rule that matches the key.
+func (r *redactor) keyAction(prefix, key string) (RedactAction, bool) {
+	for _, rule := range r.keyRules {
+		if r.keyMatches(rule, prefix, key) {
+			return rule.Action, true
+		}
+	}
+	return 0, false
+}
+
+// valueAction returns the action of the first detector rule that matches the
+// key and the value.
+func (r *redactor) valueAction(prefix, key string, value []byte) (RedactAction, bool) {
+	for _, rule := range r.valueRules {
+		if rule.Key != "" && !r.keyMatches(rule, prefix, key) {
+			continue
+		}
+		if detect(rule.Detector, value) {
+			return rule.Action, true
+		}
+	}
+	return 0, false
+}
+
+func (r *redactor) keyMatches(rule RedactRule, prefix, key string) bool {
+	if globMatch(rule.Key, key) {
+		return true
+	}
+	return prefix != "" && globMatch(rule.Key, prefix+"."+key)
+}
+
+// globMatch reports whether the string matches the pattern ignoring ASCII
+// case. An asterisk in the pattern matches any sequence of characters and a
+// question mark matches a single character.
+func globMatch(pattern, s string) bool {
+	// Position to retry from after the last asterisk
+	var starP, starS = -1, 0
+	var p, i int
+	for i < len(s) {
+		switch {
+		case p < len(pattern) && pattern[p] == '*':
+			starP, starS = p, i
+			p++
+		case p < len(pattern) && (pattern[p] == '?' || lower(pattern[p]) == lower(s[i])):
+			p++
+			i++
+		case starP >= 0:
+			starS++
+			p, i = starP+1, starS
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+func lower(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+func detect(d Detector, value []byte) bool {
+	switch d {
+	case DetectEmail:
+		return detectEmail(value)
+	case DetectCard:
+		return detectCard(value)
+	case DetectJWT:
+		return detectJWT(value)
+	case DetectBearer:
+		return detectBearer(value)
+	default:
+		return false
+	}
+}
+
+// detectEmail looks for a local part, an @ and a domain with a dot in it.
+func detectEmail(value []byte) bool {
+	for i, c := range value {
+		if c != '@' || i == 0 || !isEmailChar(value[i-1]) {
+			continue
+		}
+		var dot bool
+		j := i + 1
+		for ; j < len(value) && (isAlnum(value[j]) || value[j] == '-' || value[j] == '.'); j++ {
+			dot = dot || value[j] == '.' && j > i+1
+		}
+		if dot && value[j-1] != '.' {
+			return true
+		}
+	}
+	return false
+}
+
+// detectCard looks for 13 to 19 digits, optionally separated by single spaces
+// or dashes, that pass the Luhn check.
+func detectCard(value []byte) bool {
+	var digits [19]byte
+	for i := 0; i < len(value); i++ {
+		if !isDigit(value[i]) || i > 0 && isDigit(value[i-1]) {
+			continue
+		}
+
+		var n int
+		for j := i; j < len(value) && n <= len(digits); j++ {
+			c := value[j]
+			if isDigit(c) {
+				if n == len(digits) {
+					n++ // Too long
+					break
+				}
+				digits[n] = c - '0'
+				n++
+				continue
+			}
+			if (c == ' ' || c == '-') && j+1 < len(value) && isDigit(value[j+1]) {
+				continue
+			}
+			break
+		}
+		if n >= 13 && n <= len(digits) && luhn(digits[:n]) {
+			return true
+		}
+	}
+	return false
+}
+
+func luhn(digits []byte) bool {
+	var sum int
+	for i := range digits {
+		d := int(digits[len(digits)-1-i])
+		if i%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// detectJWT looks for three dot separated base64url segments, the first two of
+// which are JSON objects.
+func detectJWT(value []byte) bool {
+	for i := range value {
+		if !bytes.HasPrefix(value[i:], []byte("eyJ")) || i > 0 && isBase64URL(value[i-1]) {
+			continue
+		}
+		j, dots := i, 0
+		for ; j < len(value); j++ {
+			if value[j] == '.' && dots < 2 && j > i && value[j-1] != '.' {
+				dots++
+				if dots == 1 && !bytes.HasPrefix(value[j+1:], []byte("eyJ")) {
+					break
+				}
+				continue
+			}
+			if !isBase64URL(value[j]) {
+				break
+			}
+		}
+		if dots == 2 && value[j-1] != '.' {
+			return true
+		}
+	}
+	return false
+}
+
+// detectBearer looks for the word bearer followed by a token.
+func detectBearer(value []byte) bool {
+	const word = "bearer"
+	for i := 0; i+len(word) < len(value); i++ {
+		if i > 0 && isAlnum(value[i-1]) {
+			continue
+		}
+		var ok = true
+		for j := range len(word) {
+			if lower(value[i+j]) != word[j] {
+				ok = false
+				break
+			}
+		}
+		if !ok {
+			continue
+		}
+
+		j := i + len(word)
+		if value[j] != ' ' && value[j] != '\t' {
+			continue
+		}
+		for j < len(value) && (value[j] == ' ' || value[j] == '\t') {
+			j++
+		}
+		if j < len(value) && (isBase64URL(value[j]) || value[j] == '~' || value[j] == '+' || value[j] == '/') {
+			return true
+		}
+	}
+	return false
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isAlnum(c byte) bool {
+	return isDigit(c) || 'a' <= lower(c) && lower(c) <= 'z'
+}
+
+func isEmailChar(c byte) bool {
+	return isAlnum(c) || strings.IndexByte("._%+-", c) >= 0
+}
+
+func isBase64URL(c byte) bool {
+	return isAlnum(c) || c == '-' || c == '_'
+}
@@ -1,11 +1,17 @@
 package riff_test
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"io"
+	"net"
 	"os"
+	"os/exec"
+	"runtime/debug"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -277,3 +283,1328 @@ func BenchmarkPrettySortedContext(b *testing.B) {
 		)
 	}
 }
+
+func TestStackFields(t *testing.T) {
+	var out bytes.Buffer
+	l := riff.New(riff.Config{
+		Level:           riff.LevelDebug,
+		Output:          &out,
+		StackTraceLevel: riff.LevelError,
+	})
+	ctx := context.Background()
+
+	l.Warn(ctx, "Slow query", riff.Stack(), riff.Int("ms", 1500))
+	if !strings.Contains(out.String(), "TestStackFields") {
+		t.Errorf("Expected a stack trace, got %q", out.String())
+	}
+	if !strings.HasPrefix(out.String(), "WARN Slow query  ms=1500\n") {
+		t.Errorf("Unexpected entry %q", out.String())
+	}
+
+	out.Reset()
+	l.Error(ctx, "Expected failure", riff.NoStack())
+	if out.String() != "ERRO Expected failure\n" {
+		t.Errorf("Expected no stack trace, got %q", out.String())
+	}
+
+	out.Reset()
+	l.Error(riff.WithContext(ctx, riff.NoStack()), "Expected failure", riff.Stack())
+	if !strings.Contains(out.String(), "TestStackFields") {
+		t.Errorf("Expected entry field to override context, got %q", out.String())
+	}
+
+	out.Reset()
+	l.Info(ctx, "Hang detected", riff.AllGoroutines())
+	if !strings.Contains(out.String(), "goroutine ") {
+		t.Errorf("Expected goroutine dump, got %q", out.String())
+	}
+}
+
+func TestSampling(t *testing.T) {
+	var out bytes.Buffer
+	l := riff.New(riff.Config{
+		Level:           riff.LevelDebug,
+		Output:          &out,
+		StackTraceLevel: riff.LevelFatal,
+		Sampling: riff.Sampling{
+			Interval:   time.Hour,
+			First:      2,
+			Thereafter: 3,
+		},
+	})
+	ctx := context.Background()
+
+	for range 10 {
+		l.Info(ctx, "Tick")
+	}
+	l.Warn(ctx, "Tick")
+	if n := strings.Count(out.String(), "INFO Tick\n"); n != 4 {
+		t.Errorf("Expected 4 sampled entries, got %d", n)
+	}
+	if n := strings.Count(out.String(), "WARN Tick\n"); n != 1 {
+		t.Errorf("Expected level to be a part of the key, got %d entries", n)
+	}
+	if n := l.Dropped(); n != 6 {
+		t.Errorf("Expected 6 dropped entries, got %d", n)
+	}
+}
+
+func TestSamplingCollision(t *testing.T) {
+	var out bytes.Buffer
+	l := riff.New(riff.Config{
+		Level:           riff.LevelDebug,
+		Output:          &out,
+		StackTraceLevel: riff.LevelFatal,
+		Sampling: riff.Sampling{
+			Interval: time.Hour,
+			First:    1,
+		},
+	})
+	ctx := context.Background()
+
+	// Both keys fall into the same sampler bucket
+	for range 5 {
+		l.Info(ctx, "Tick")
+	}
+	l.Error(ctx, "Payment failed 2511")
+	l.Error(ctx, "Payment failed 2511")
+
+	exp := "INFO Tick\n" +
+		"ERRO Payment failed 2511\n"
+	if out.String() != exp {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+}
+
+func TestSamplingByCaller(t *testing.T) {
+	var out bytes.Buffer
+	log.Setup(riff.Config{
+		Level:           riff.LevelDebug,
+		Output:          &out,
+		StackTraceLevel: riff.LevelFatal,
+		StackTraceSkip:  4,
+		Sampling: riff.Sampling{
+			Interval: time.Hour,
+			First:    1,
+			ByCaller: true,
+		},
+	})
+	ctx := context.Background()
+
+	for range 5 {
+		log.Info(ctx, "Tick", log.Int("site", 1))
+		log.Info(ctx, "Tick", log.Int("site", 2))
+	}
+	if out.String() != "INFO Tick  site=1\nINFO Tick  site=2\n" {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	var out bytes.Buffer
+	l := riff.New(riff.Config{
+		Level:           riff.LevelDebug,
+		Output:          &out,
+		StackTraceLevel: riff.LevelFatal,
+		RateLimits: map[riff.Level]riff.RateLimit{
+			riff.LevelDebug: {Rate: 20, Burst: 2},
+		},
+	})
+	ctx := context.Background()
+
+	for i := range 5 {
+		l.Debug(ctx, "Polling", riff.Int("i", i))
+		l.Info(ctx, "Polling", riff.Int("i", i))
+	}
+	time.Sleep(100 * time.Millisecond)
+	l.Debug(ctx, "Polling", riff.Int("i", 5))
+
+	exp := "DEBU Polling  i=0\n" +
+		"INFO Polling  i=0\n" +
+		"DEBU Polling  i=1\n" +
+		"INFO Polling  i=1\n" +
+		"INFO Polling  i=2\n" +
+		"INFO Polling  i=3\n" +
+		"INFO Polling  i=4\n" +
+		"DEBU Rate limited, entries suppressed  suppressed=3\n" +
+		"DEBU Polling  i=5\n"
+	if out.String() != exp {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+	if n := l.Dropped(); n != 3 {
+		t.Errorf("Expected 3 dropped entries, got %d", n)
+	}
+}
+
+func TestRateLimitSilence(t *testing.T) {
+	var out safeBuffer
+	l := riff.New(riff.Config{
+		Level:           riff.LevelDebug,
+		Output:          &out,
+		StackTraceLevel: riff.LevelFatal,
+		RateLimits: map[riff.Level]riff.RateLimit{
+			riff.LevelDebug: {Rate: 20, Burst: 1},
+		},
+	})
+	ctx := context.Background()
+
+	// Suppressed entries are reported even if nothing follows the burst
+	for i := range 3 {
+		l.Debug(ctx, "Polling", riff.Int("i", i))
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	exp := "DEBU Polling  i=0\n" +
+		"DEBU Rate limited, entries suppressed  suppressed=2\n"
+	if out.String() != exp {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+}
+
+func TestDedup(t *testing.T) {
+	var out bytes.Buffer
+	l := riff.New(riff.Config{
+		Level:           riff.LevelDebug,
+		Output:          &out,
+		TimeFormat:      "2006",
+		StackTraceLevel: riff.LevelFatal,
+		DedupTimeout:    time.Hour,
+	})
+	ctx := context.Background()
+
+	for range 4 {
+		l.Warn(ctx, "Reconnecting", riff.Str("addr", "db:5432"))
+	}
+	l.Info(ctx, "Connected")
+	l.Info(ctx, "Connected")
+	l.Flush()
+
+	now := time.Now().Format("2006")
+	exp := "WARN Reconnecting  addr=db:5432\n" +
+		"WARN Reconnecting  addr=db:5432 repeated=3 first=" + now + " last=" + now + "\n" +
+		"INFO Connected\n" +
+		"INFO Connected  repeated=1 first=" + now + " last=" + now + "\n"
+	if out.String() != exp {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+}
+
+func TestDedupTimeout(t *testing.T) {
+	var out safeBuffer
+	l := riff.New(riff.Config{
+		Level:           riff.LevelDebug,
+		Output:          &out,
+		StackTraceLevel: riff.LevelFatal,
+		DedupTimeout:    20 * time.Millisecond,
+	})
+	ctx := context.Background()
+
+	l.Info(ctx, "Reconnecting")
+	l.Info(ctx, "Reconnecting")
+	time.Sleep(50 * time.Millisecond)
+	if !strings.Contains(out.String(), "INFO Reconnecting  repeated=1 ") {
+		t.Errorf("Expected summary to be written after timeout, got %q", out.String())
+	}
+}
+
+func TestDedupContextBuffer(t *testing.T) {
+	var out bytes.Buffer
+	l := riff.New(riff.Config{
+		Level:           riff.LevelInfo,
+		Output:          &out,
+		StackTraceLevel: riff.LevelFatal,
+		ContextBuffers:  true,
+		DedupTimeout:    time.Hour,
+	})
+	ctx := riff.WithBuffer(context.Background(), 10)
+
+	l.Error(ctx, "Boom")
+	l.Debug(ctx, "Retrying")
+	l.Error(ctx, "Boom")
+	l.Flush()
+
+	exp := "ERRO Boom\n" +
+		"DEBU Retrying\n" +
+		"ERRO Boom\n"
+	if out.String() != exp {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+}
+
+// raceEnabled is true if the tests are built with the race detector, which
+// makes allocation counts unreliable.
+var raceEnabled = func() bool {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return false
+	}
+	for _, s := range info.Settings {
+		if s.Key == "-race" {
+			return s.Value == "true"
+		}
+	}
+	return false
+}()
+
+// safeBuffer is a buffer that can be written to by background goroutines.
+type safeBuffer struct {
+	buf  bytes.Buffer
+	lock sync.Mutex
+}
+
+func (b *safeBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *safeBuffer) String() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.String()
+}
+
+func TestContextBuffer(t *testing.T) {
+	var out bytes.Buffer
+	l := riff.New(riff.Config{
+		Level:           riff.LevelInfo,
+		Output:          &out,
+		StackTraceLevel: riff.LevelFatal,
+		ContextBuffers:  true,
+	})
+
+	ctx := riff.WithBuffer(context.Background(), 2)
+	l.Debug(ctx, "Parsing request", riff.Int("step", 1))
+	l.Trace(ctx, "Parsing request", riff.Int("step", 2))
+	l.Debug(ctx, "Parsing request", riff.Int("step", 3))
+	l.Info(ctx, "Request received")
+	l.Error(ctx, "Request failed")
+	l.Error(ctx, "Request failed again")
+
+	exp := "INFO Request received\n" +
+		"TRAC Parsing request  step=2\n" +
+		"DEBU Parsing request  step=3\n" +
+		"ERRO Request failed\n" +
+		"ERRO Request failed again\n"
+	if out.String() != exp {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+
+	out.Reset()
+	ctx = riff.WithBuffer(context.Background(), 10)
+	l.Debug(ctx, "Parsing request")
+	l.Warn(ctx, "Request is slow")
+	if out.String() != "WARN Request is slow\n" {
+		t.Errorf("Expected buffered entries to be held back, got %q", out.String())
+	}
+}
+
+func TestContextLevel(t *testing.T) {
+	var out bytes.Buffer
+	l := riff.New(riff.Config{
+		Level:           riff.LevelInfo,
+		Output:          &out,
+		StackTraceLevel: riff.LevelFatal,
+		ContextLevels:   true,
+	})
+
+	ctx := context.Background()
+	l.Debug(ctx, "Regular request")
+	ctx = riff.WithLevel(ctx, riff.LevelTrace)
+	l.Trace(ctx, "Debugged request")
+	l.Warn(ctx, "Debugged request")
+
+	exp := "TRAC Debugged request\n" +
+		"WARN Debugged request\n"
+	if out.String() != exp {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+
+	out.Reset()
+	l = riff.New(riff.Config{
+		Level:           riff.LevelInfo,
+		Output:          &out,
+		StackTraceLevel: riff.LevelFatal,
+	})
+	l.Debug(ctx, "Overrides are disabled")
+	if out.Len() > 0 {
+		t.Errorf("Expected context level to be ignored, got %q", out.String())
+	}
+}
+
+func TestLevelSpec(t *testing.T) {
+	spec, err := riff.ParseLevelSpec("warn, db=debug, db.pool=error, http*=info")
+	if err != nil {
+		t.Fatalf("Failed to parse level spec: %v", err)
+	}
+	if s := spec.String(); s != "warn,db=debug,db.pool=error,http*=info" {
+		t.Errorf("Unexpected level spec string %q", s)
+	}
+	for _, s := range []string{"verbose", "db=", "=info"} {
+		if _, err := riff.ParseLevelSpec(s); err == nil {
+			t.Errorf("Expected level spec %q to be invalid", s)
+		}
+	}
+
+	var out bytes.Buffer
+	l := riff.New(riff.Config{
+		Level:           riff.LevelTrace,
+		Output:          &out,
+		StackTraceLevel: riff.LevelFatal,
+		StackTraceSkip:  3,
+		LevelSpec:       spec,
+	})
+	ctx := context.Background()
+
+	l.Info(ctx, "Root")
+	l.Warn(ctx, "Root")
+	db := l.Named("db")
+	db.Debug(ctx, "DB")
+	db.Named("pool").Warn(ctx, "Pool")
+	db.Named("pool").Error(ctx, "Pool")
+	l.Named("httpclient").Info(ctx, "HTTP")
+	l.Named("dbx").Info(ctx, "DBX")
+
+	exp := "WARN Root\n" +
+		"DEBU DB\n" +
+		"ERRO Pool\n" +
+		"INFO HTTP\n"
+	if out.String() != exp {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+
+	out.Reset()
+	spec, _ = riff.ParseLevelSpec("debug,github.com/localhots/riff_test=error")
+	l.SetLevelSpec(spec)
+	db.Debug(ctx, "DB")
+	if out.Len() > 0 {
+		t.Errorf("Expected package rule to apply, got %q", out.String())
+	}
+
+	l.SetLevelSpec(nil)
+	db.Trace(ctx, "DB")
+	if out.String() != "TRAC DB\n" {
+		t.Errorf("Expected configured level to be restored, got %q", out.String())
+	}
+
+	// Changes to a spec in use only take effect when it is set again
+	out.Reset()
+	l.SetLevelSpec(spec)
+	if err := spec.Set("trace"); err != nil {
+		t.Fatalf("Failed to set level spec: %v", err)
+	}
+	db.Debug(ctx, "DB")
+	if out.Len() > 0 {
+		t.Errorf("Expected spec in use to be unchanged, got %q", out.String())
+	}
+	l.SetLevelSpec(spec)
+	db.Debug(ctx, "DB")
+	if out.String() != "DEBU DB\n" {
+		t.Errorf("Expected changed spec to apply, got %q", out.String())
+	}
+}
+
+func TestLevelSpecMainPackage(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping test that builds a program")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("Go command not found")
+	}
+
+	// Packages without a slash in the path can only be tested from a program
+	out, err := exec.Command("go", "run", "./testdata/levelmain", "warn,main=debug").CombinedOutput()
+	if err != nil {
+		t.Fatalf("Failed to run program: %v\n%s", err, out)
+	}
+	exp := "DEBU Main\n" +
+		"DEBU DB\n" +
+		"INFO Info\n"
+	if string(out) != exp {
+		t.Errorf("Unexpected output %q", out)
+	}
+}
+
+func TestEnabled(t *testing.T) {
+	var out bytes.Buffer
+	l := riff.New(riff.Config{
+		Level:           riff.LevelTrace - 5,
+		Output:          &out,
+		StackTraceLevel: riff.LevelFatal,
+		ContextLevels:   true,
+	})
+	ctx := context.Background()
+
+	l.Trace(ctx, "Below the lowest level")
+	l.Log(ctx, riff.LevelWarn, "Generic")
+	exp := "TRAC Below the lowest level\n" +
+		"WARN Generic\n"
+	if out.String() != exp {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+
+	l = riff.New(riff.Config{
+		Level:          riff.LevelInfo,
+		Output:         &out,
+		StackTraceSkip: 3,
+		ContextLevels:  true,
+	})
+	if l.Enabled(ctx, riff.LevelDebug) || !l.Enabled(ctx, riff.LevelInfo) {
+		t.Error("Expected only Info and above to be enabled")
+	}
+	if !l.Enabled(riff.WithLevel(ctx, riff.LevelDebug), riff.LevelDebug) {
+		t.Error("Expected context level to enable Debug")
+	}
+
+	spec, _ := riff.ParseLevelSpec("warn,github.com/localhots/riff_test=trace")
+	l.SetLevelSpec(spec)
+	if !l.Enabled(ctx, riff.LevelTrace) {
+		t.Error("Expected package rule to enable Trace")
+	}
+	spec, _ = riff.ParseLevelSpec("info,github.com/localhots/riff_test=error")
+	l.SetLevelSpec(spec)
+	if l.Enabled(ctx, riff.LevelWarn) {
+		t.Error("Expected package rule to disable Warn")
+	}
+}
+
+// The global logger before any of the tests set it up.
+var defaultLogger = log.L()
+
+func TestReplace(t *testing.T) {
+	if defaultLogger == nil {
+		t.Fatal("Expected a default logger")
+	}
+	// Other tests set up their own global loggers
+	defer log.Replace(defaultLogger)()
+
+	ctx := context.Background()
+	if !log.Enabled(ctx, riff.LevelInfo) || log.Enabled(ctx, riff.LevelDebug) {
+		t.Error("Expected the default logger to log at the Info level")
+	}
+	prev := log.L()
+
+	var out bytes.Buffer
+	l := riff.New(riff.Config{
+		Level:           riff.LevelInfo,
+		Output:          &out,
+		StackTraceLevel: riff.LevelFatal,
+	})
+	restore := log.Replace(l)
+	if log.L() != l {
+		t.Error("Expected logger to be replaced")
+	}
+	log.Info(ctx, "Replaced")
+	if out.String() != "INFO Replaced\n" {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+
+	restore()
+	if log.L() != prev {
+		t.Error("Expected previous logger to be restored")
+	}
+}
+
+func TestEvent(t *testing.T) {
+	var out bytes.Buffer
+	l := riff.New(riff.Config{
+		Level:           riff.LevelInfo,
+		Output:          &out,
+		StackTraceLevel: riff.LevelFatal,
+		SortFields:      true,
+	})
+	ctx := riff.WithContext(context.Background(), riff.Str("a", "ctx"))
+
+	l.Event(ctx, riff.LevelInfo).
+		Str("status", "success").
+		Int("task_id", 123456).
+		Bool("retry", false).
+		Duration("took", 1500*time.Millisecond).
+		Field(riff.Float64("ratio", 0.5)).
+		Msg("Task finished")
+	if out.String() != "INFO Task finished  status=success task_id=123456 retry=false took=1.5s ratio=0.5 a=ctx\n" {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+
+	out.Reset()
+	ev := l.Event(ctx, riff.LevelDebug)
+	if ev != nil {
+		t.Fatalf("Expected nil event for disabled level")
+	}
+	ev.Str("a", "b").Int("n", 1).Cause(nil).Msg("Discarded")
+	if out.Len() > 0 {
+		t.Errorf("Expected disabled event to be discarded, got %q", out.String())
+	}
+
+	out.Reset()
+	l.Event(ctx, riff.LevelWarn).Stack().Msg("With stack")
+	if !strings.Contains(out.String(), "TestEvent") {
+		t.Errorf("Expected a stack trace, got %q", out.String())
+	}
+}
+
+func TestEventAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("The race detector allocates")
+	}
+	l := riff.New(riff.Config{
+		Level:           riff.LevelInfo,
+		Output:          io.Discard,
+		StackTraceLevel: riff.LevelFatal,
+	})
+	ctx := context.Background()
+
+	allocs := testing.AllocsPerRun(100, func() {
+		l.Event(ctx, riff.LevelInfo).Str("status", "success").Int("task_id", 123456).Msg("Starting task")
+		l.Event(ctx, riff.LevelDebug).Str("status", "success").Int("task_id", 123456).Msg("Starting task")
+	})
+	if allocs > 0 {
+		t.Errorf("Expected no allocations, got %.1f", allocs)
+	}
+}
+
+func TestLazy(t *testing.T) {
+	var out bytes.Buffer
+	l := riff.New(riff.Config{
+		Level:           riff.LevelInfo,
+		Output:          &out,
+		StackTraceLevel: riff.LevelFatal,
+	})
+	ctx := context.Background()
+
+	var calls int
+	stats := func() any {
+		calls++
+		return 42
+	}
+	body := func() string {
+		calls++
+		return `{"id":1}`
+	}
+
+	l.Debug(ctx, "Cache stats", riff.Lazy("hits", stats), riff.LazyStr("body", body))
+	if calls > 0 {
+		t.Errorf("Expected lazy fields not to be evaluated for disabled levels")
+	}
+
+	l.Info(ctx, "Cache stats", riff.Lazy("hits", stats), riff.LazyStr("body", body))
+	if calls != 2 {
+		t.Errorf("Expected each lazy field to be evaluated once, got %d calls", calls)
+	}
+	if out.String() != "INFO Cache stats  hits=42 body={\"id\":1}\n" {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+
+	out.Reset()
+	l = riff.New(riff.Config{
+		Level:            riff.LevelInfo,
+		Output:           &out,
+		StackTraceLevel:  riff.LevelFatal,
+		MessageTemplates: true,
+	})
+	var n int
+	counter := func() any {
+		n++
+		return n
+	}
+	l.Info(ctx, "v={v}", riff.Lazy("v", counter))
+	if n != 1 {
+		t.Errorf("Expected templated lazy field to be evaluated once, got %d calls", n)
+	}
+	if out.String() != "INFO v=1  v=1\n" {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+}
+
+func TestPrintf(t *testing.T) {
+	var out bytes.Buffer
+	l := riff.New(riff.Config{
+		Level:           riff.LevelInfo,
+		Output:          &out,
+		StackTraceLevel: riff.LevelFatal,
+	})
+	ctx := context.Background()
+
+	l.Infof(ctx, "Processed %d items in %s", 3, 2*time.Second)
+	l.Warnf(ctx, "Processed %{items}d items in %{took}s (%.*f%%)", 3, 2*time.Second, 1, 99.5)
+	l.Debugf(ctx, "Skipped %v", stringerFunc(func() string {
+		t.Errorf("Expected arguments not to be formatted for disabled levels")
+		return ""
+	}))
+	exp := "INFO Processed 3 items in 2s\n" +
+		"WARN Processed 3 items in 2s (99.5%)  items=3 took=2s\n"
+	if out.String() != exp {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+
+	out.Reset()
+	l = riff.New(riff.Config{
+		Level:           riff.LevelInfo,
+		Output:          &out,
+		StackTraceLevel: riff.LevelFatal,
+		FormatArgs:      true,
+	})
+	l.Infof(ctx, "Processed %d items in %{took}s", 3, 2*time.Second)
+	if out.String() != "INFO Processed 3 items in 2s  arg0=3 took=2s\n" {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+}
+
+type stringerFunc func() string
+
+func (fn stringerFunc) String() string {
+	return fn()
+}
+
+func TestMessageTemplates(t *testing.T) {
+	var out bytes.Buffer
+	l := riff.New(riff.Config{
+		Level:            riff.LevelInfo,
+		Output:           &out,
+		StackTraceLevel:  riff.LevelFatal,
+		MessageTemplates: true,
+	})
+	ctx := riff.WithContext(context.Background(), riff.Str("user", "bob"))
+
+	l.Info(ctx, "Task {task_id} finished with {status} for {user}, {unknown} and {not a key}",
+		riff.Int("task_id", 123456),
+		riff.Str("status", "success"),
+	)
+	exp := "INFO Task 123456 finished with success for bob, {unknown} and {not a key}  task_id=123456 status=success user=bob\n"
+	if out.String() != exp {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		l.Info(ctx, "Task {task_id} finished", riff.Int("task_id", 123456))
+	})
+	if allocs > 0 && !raceEnabled {
+		t.Errorf("Expected no allocations, got %.1f", allocs)
+	}
+}
+
+func TestGroups(t *testing.T) {
+	var out bytes.Buffer
+	l := riff.New(riff.Config{
+		Level:            riff.LevelInfo,
+		Output:           &out,
+		StackTraceLevel:  riff.LevelFatal,
+		SortFields:       true,
+		MessageTemplates: true,
+	})
+	ctx := riff.WithContext(context.Background(), riff.Str("user", "bob"), riff.Str("app", "api"))
+	ctx = riff.WithContext(ctx, riff.Group("req", riff.Str("id", "a1")))
+
+	l.Info(ctx, "Request {http.method} {http.path} {req.id}",
+		riff.Group("http",
+			riff.Str("path", "/"),
+			riff.Str("method", "GET"),
+			riff.Group("resp", riff.Int("status", 200)),
+		),
+		riff.Group("empty", riff.Stack()),
+	)
+	exp := "INFO Request GET / a1  app=api http.method=GET http.path=/ http.resp.status=200 req.id=a1 user=bob\n"
+	if out.String() != exp {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+
+	out.Reset()
+	l.WithGroup("db").WithGroup("query").Info(ctx, "Query", riff.Int("rows", 3), riff.Str("table", "users"))
+	exp = "INFO Query  app=api db.query.rows=3 db.query.table=users req.id=a1 user=bob\n"
+	if out.String() != exp {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+
+	out.Reset()
+	l.WithGroup("db").Info(ctx, "Query {db.table} {table} for {user}", riff.Str("table", "users"))
+	exp = "INFO Query users {table} for bob  app=api db.table=users req.id=a1 user=bob\n"
+	if out.String() != exp {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+
+	out.Reset()
+	l.WithGroup("db").Event(ctx, riff.LevelInfo).Int("rows", 3).Msg("Query")
+	exp = "INFO Query  db.rows=3 app=api req.id=a1 user=bob\n"
+	if out.String() != exp {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+}
+
+type testUser struct {
+	id    int
+	name  string
+	roles testRoles
+}
+
+func (u *testUser) LogObject(enc riff.ObjectEncoder) {
+	enc.AddInt("id", u.id)
+	enc.AddStr("name", u.name)
+	enc.AddArray("roles", &u.roles)
+}
+
+type testRoles []string
+
+func (r *testRoles) LogArray(enc riff.ArrayEncoder) {
+	for _, role := range *r {
+		enc.AppendStr(role)
+	}
+}
+
+func TestMarshalers(t *testing.T) {
+	var out bytes.Buffer
+	l := riff.New(riff.Config{
+		Level:           riff.LevelInfo,
+		Output:          &out,
+		StackTraceLevel: riff.LevelFatal,
+	})
+	ctx := context.Background()
+	u := &testUser{id: 1, name: "bob", roles: testRoles{"admin", "dev"}}
+
+	l.Info(ctx, "User", riff.Object("user", u), riff.Any("roles", &u.roles), riff.Array("none", nil))
+	exp := "INFO User  user={id=1,name=bob,roles=[admin,dev]} roles=[admin,dev] none=<nil>\n"
+	if out.String() != exp {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+
+	out.Reset()
+	l.Event(ctx, riff.LevelInfo).Object("user", u).Msg("User")
+	exp = "INFO User  user={id=1,name=bob,roles=[admin,dev]}\n"
+	if out.String() != exp {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+
+	f := riff.Object("user", u)
+	allocs := testing.AllocsPerRun(100, func() {
+		l.Info(ctx, "User", f)
+	})
+	if allocs > 0 && !raceEnabled {
+		t.Errorf("Expected no allocations, got %.1f", allocs)
+	}
+}
+
+func TestSlices(t *testing.T) {
+	var out bytes.Buffer
+	l := riff.New(riff.Config{
+		Level:           riff.LevelInfo,
+		Output:          &out,
+		StackTraceLevel: riff.LevelFatal,
+	})
+	ctx := context.Background()
+
+	l.Info(ctx, "Slices",
+		riff.Strs("strs", []string{"a", "b"}),
+		riff.Ints("ints", []int{1, 2, 3}),
+		riff.Any("floats", []float64{1.5, 2}),
+		riff.Any("durs", []time.Duration{time.Second, 1500 * time.Millisecond}),
+		riff.Bools("none", nil),
+		riff.Any("map", map[string]string{"b": "2", "a": "1"}),
+	)
+	exp := "INFO Slices  strs=[a,b] ints=[1,2,3] floats=[1.5,2] durs=[1s,1.5s] none=[] map={a=1,b=2}\n"
+	if out.String() != exp {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+
+	riff.MaxElements = 2
+	defer func() { riff.MaxElements = 0 }()
+
+	out.Reset()
+	l.Info(ctx, "Slices", riff.Ints("ints", []int{1, 2, 3, 4, 5}), riff.StrMap("map", map[string]string{"a": "1", "b": "2", "c": "3"}))
+	exp = "INFO Slices  ints=[1,2,…+3] map={a=1,b=2,…+1}\n"
+	if out.String() != exp {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+}
+
+type testRequest struct {
+	ID       int               `log:"id"`
+	Method   string            `log:"method"`
+	Password string            `log:"-"`
+	Token    string            `log:"token,redact"`
+	Comment  string            `log:",omitempty"`
+	Headers  map[string]string `log:"headers"`
+	User     *testUser         `log:"user,omitempty"`
+	Parent   *testRequest      `log:"parent,omitempty"`
+	Took     time.Duration
+	internal int
+}
+
+func TestStruct(t *testing.T) {
+	var out bytes.Buffer
+	l := riff.New(riff.Config{
+		Level:           riff.LevelInfo,
+		Output:          &out,
+		StackTraceLevel: riff.LevelFatal,
+	})
+	ctx := context.Background()
+
+	req := &testRequest{
+		ID:       1,
+		Method:   "GET",
+		Password: "secret",
+		Token:    "secret",
+		Headers:  map[string]string{"b": "2", "a": "1"},
+		User:     &testUser{id: 2, name: "bob"},
+		Took:     time.Second,
+	}
+	req.Parent = req
+
+	l.Info(ctx, "Request", riff.Any("req", req), riff.Any("err", errors.New("boom")))
+	exp := "INFO Request  req={id=1,method=GET,token=[REDACTED],headers={a=1,b=2},user={id=2,name=bob,roles=[]},parent=<cycle>,Took=1s} err=boom\n"
+	if out.String() != exp {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+
+	type node struct {
+		Next *node
+	}
+	n := &node{}
+	for range 10 {
+		n = &node{Next: n}
+	}
+	out.Reset()
+	l.Info(ctx, "Nested", riff.Struct("node", n))
+	exp = "INFO Nested  node={Next={Next={Next={Next={Next={…}}}}}}\n"
+	if out.String() != exp {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+}
+
+type testID int
+
+func (id *testID) String() string {
+	return "id-" + strconv.Itoa(int(*id))
+}
+
+type testText string
+
+func (t testText) MarshalText() ([]byte, error) {
+	if t == "" {
+		return nil, errors.New("empty")
+	}
+	return []byte("text:" + t), nil
+}
+
+// String is never used, MarshalText takes precedence.
+func (t testText) String() string {
+	return "string:" + string(t)
+}
+
+type testPanicker struct{}
+
+func (testPanicker) Error() string {
+	panic("boom")
+}
+
+func TestAny(t *testing.T) {
+	var out bytes.Buffer
+	l := riff.New(riff.Config{
+		Level:           riff.LevelInfo,
+		Output:          &out,
+		StackTraceLevel: riff.LevelFatal,
+	})
+	ctx := context.Background()
+
+	id := testID(5)
+	n := 7
+	var nilID *testID
+	var nilInt *int
+	l.Info(ctx, "Values",
+		riff.Any("id", &id),
+		riff.Any("nil_id", nilID),
+		riff.Any("ptr", &n),
+		riff.Any("nil_ptr", nilInt),
+		riff.Any("nil", nil),
+		riff.Any("text", testText("a")),
+		riff.Any("bad_text", testText("")),
+		riff.Any("panic", testPanicker{}),
+		riff.Any("ip", net.IPv4(127, 0, 0, 1)),
+	)
+	exp := "INFO Values  id=id-5 nil_id=<nil> ptr=7 nil_ptr=<nil> nil=<nil> text=text:a bad_text=<error: empty> panic=<panic: boom> ip=127.0.0.1\n"
+	if out.String() != exp {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+
+	var calls int
+	f := riff.Stringer("lazy", stringerFunc(func() string {
+		calls++
+		return "value"
+	}))
+	out.Reset()
+	l.Debug(ctx, "Skipped", f)
+	l.Info(ctx, "Written", f)
+	if calls != 1 {
+		t.Errorf("Expected String to be called once, got %d", calls)
+	}
+	if out.String() != "INFO Written  lazy=value\n" {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+}
+
+func TestRedaction(t *testing.T) {
+	var out bytes.Buffer
+	l := riff.New(riff.Config{
+		Level:            riff.LevelInfo,
+		Output:           &out,
+		StackTraceLevel:  riff.LevelFatal,
+		MessageTemplates: true,
+		Redaction: riff.Redaction{
+			Rules: []riff.RedactRule{
+				{Key: "*password*"},
+				{Key: "authorization", Action: riff.RedactDrop},
+				{Key: "user_id", Action: riff.RedactHash},
+				{Detector: riff.DetectEmail, Action: riff.RedactHash},
+				{Detector: riff.DetectCard},
+				{Detector: riff.DetectJWT},
+				{Detector: riff.DetectBearer},
+			},
+			HashKey: []byte("key"),
+		},
+	})
+	ctx := riff.WithContext(context.Background(), riff.Str("db_password", "hunter2"))
+
+	l.Info(ctx, "Request with {api_key} by {http.authorization}",
+		riff.Str("email", "Contact bob@example.com"),
+		riff.Group("http",
+			riff.Str("authorization", "Basic Ym9iOmh1bnRlcjI="),
+			riff.Str("header", "Bearer abc.def"),
+		),
+		riff.Str("card", "4111 1111 1111 1111"),
+		riff.Str("order", "4111 1111 1111 1112"),
+		riff.Str("token", "eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiIxIn0.c2ln"),
+		riff.Str("user_id", "42"),
+		riff.Secret("api_key", "s3cr3t"),
+	)
+	out1 := out.String()
+	for _, s := range []string{"bob@example.com", "hunter2", "Ym9i", "abc.def", "4111 1111 1111 1111", "eyJ", "s3cr3t", "user_id=42"} {
+		if strings.Contains(out1, s) {
+			t.Errorf("Output contains %q: %q", s, out1)
+		}
+	}
+	for _, s := range []string{"Request with hmac:", "by {http.authorization}", "http.header=[REDACTED]", "card=[REDACTED]", "order=4111 1111 1111 1112", "token=[REDACTED]", "db_password=[REDACTED]", "email=hmac:", "api_key=hmac:"} {
+		if !strings.Contains(out1, s) {
+			t.Errorf("Output doesn't contain %q: %q", s, out1)
+		}
+	}
+
+	// Hashes are stable
+	out.Reset()
+	l.Event(ctx, riff.LevelInfo).Str("user_id", "42").Msg("Again")
+	if i := strings.Index(out1, "user_id="); i < 0 || !strings.Contains(out.String(), out1[i:i+len("user_id=hmac:")+16]) {
+		t.Errorf("Expected the same hash in %q", out.String())
+	}
+}
+
+func TestRedactNestedKeys(t *testing.T) {
+	var out bytes.Buffer
+	l := riff.New(riff.Config{
+		Level:           riff.LevelInfo,
+		Output:          &out,
+		StackTraceLevel: riff.LevelFatal,
+		Redaction: riff.Redaction{
+			Rules: []riff.RedactRule{
+				{Key: "*password*"},
+				{Key: "token", Action: riff.RedactDrop},
+			},
+		},
+	})
+	ctx := context.Background()
+
+	type creds struct {
+		User     string
+		Password string
+		Token    string
+	}
+	type login struct {
+		Creds   creds
+		Headers map[string]string
+	}
+	v := login{
+		Creds:   creds{User: "bob", Password: "hunter2", Token: "t0k3n"},
+		Headers: map[string]string{"X-Password": "hunter2", "Accept": "*/*"},
+	}
+	l.Info(ctx, "Login",
+		riff.Struct("struct", v),
+		riff.Any("any", &v.Creds),
+		riff.StrMap("map", map[string]string{"db_password": "hunter2", "host": "db"}),
+	)
+	exp := "INFO Login  struct={Creds={User=bob,Password=[REDACTED]},Headers={Accept=*/*,X-Password=[REDACTED]}} any={User=bob,Password=[REDACTED]} map={db_password=[REDACTED],host=db}\n"
+	if out.String() != exp {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+}
+
+func TestRedactFormatArgs(t *testing.T) {
+	var out bytes.Buffer
+	l := riff.New(riff.Config{
+		Level:           riff.LevelInfo,
+		Output:          &out,
+		StackTraceLevel: riff.LevelFatal,
+		Redaction: riff.Redaction{
+			Rules: []riff.RedactRule{
+				{Key: "*password*"},
+				{Key: "pin", Action: riff.RedactDrop},
+				{Detector: riff.DetectEmail},
+			},
+		},
+	})
+	ctx := context.Background()
+
+	l.Infof(ctx, "User %{email}s logged in with %{password}q and %{pin}d from %s, %d attempts",
+		"a@b.com", "hunter2", 1234, "c@d.org", 3)
+	exp := "INFO User [REDACTED] logged in with [REDACTED] and [REDACTED] from [REDACTED], 3 attempts  email=[REDACTED] password=[REDACTED]\n"
+	if out.String() != exp {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+}
+
+func TestRedactHashWithoutKey(t *testing.T) {
+	var out bytes.Buffer
+	l := riff.New(riff.Config{
+		Level:           riff.LevelInfo,
+		Output:          &out,
+		StackTraceLevel: riff.LevelFatal,
+		Redaction: riff.Redaction{
+			Rules: []riff.RedactRule{
+				{Key: "user_id", Action: riff.RedactHash},
+				{Detector: riff.DetectEmail, Action: riff.RedactHash},
+			},
+		},
+	})
+	ctx := context.Background()
+
+	// Unkeyed hashes of guessable values can be reversed, so they are masked
+	l.Info(ctx, "Login", riff.Str("user_id", "42"), riff.Str("contact", "bob@example.com"), riff.Secret("api_key", "s3cr3t"))
+	exp := "INFO Login  user_id=[REDACTED] contact=[REDACTED] api_key=[REDACTED]\n"
+	if out.String() != exp {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+}
+
+func TestTruncation(t *testing.T) {
+	var out bytes.Buffer
+	l := riff.New(riff.Config{
+		Level:            riff.LevelInfo,
+		Output:           &out,
+		StackTraceLevel:  riff.LevelFatal,
+		MaxMessageLength: 10,
+		MaxValueLength:   5,
+	})
+	ctx := context.Background()
+
+	l.Info(ctx, "Message that is too long", riff.Str("body", "héllo world"), riff.Int("n", 123))
+	exp := "INFO Message th…(truncated 14 bytes)  body=héll…(truncated 7 bytes) n=123\n"
+	if out.String() != exp {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+
+	out.Reset()
+	l.Event(ctx, riff.LevelInfo).Str("body", "hello world").Msg("Event")
+	exp = "INFO Event  body=hello…(truncated 6 bytes)\n"
+	if out.String() != exp {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+
+	out.Reset()
+	l = riff.New(riff.Config{
+		Level:           riff.LevelInfo,
+		Output:          &out,
+		StackTraceLevel: riff.LevelFatal,
+		MaxEntryBytes:   20,
+	})
+	l.Info(ctx, "Entry", riff.Str("body", strings.Repeat("x", 100)))
+	exp = "INFO Entry  body=xxx…(truncated 97 bytes)\n"
+	if out.String() != exp {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+}
+
+func TestMessageWidth(t *testing.T) {
+	var out bytes.Buffer
+	l := riff.New(riff.Config{
+		Level:           riff.LevelInfo,
+		Output:          &out,
+		StackTraceLevel: riff.LevelFatal,
+		MinMessageWidth: 10,
+	})
+	ctx := context.Background()
+
+	for _, msg := range []string{"ascii", "Привет", "日本語", "caf\u00e9", "cafe\u0301", "🚀 go", "\033[1mbold\033[0m"} {
+		out.Reset()
+		l.Info(ctx, msg, riff.Int("n", 1))
+		line := strings.TrimPrefix(out.String(), "INFO ")
+		col := strings.Index(line, "n=1")
+		width := len([]rune(line[:col]))
+		switch msg {
+		case "日本語":
+			width += 3
+		case "cafe\u0301":
+			width--
+		case "🚀 go":
+			width++
+		case "\033[1mbold\033[0m":
+			width -= 8
+		}
+		if width != 12 {
+			t.Errorf("Expected fields of %q to start at column 12, got %d", msg, width)
+		}
+	}
+}
+
+func TestTableLayout(t *testing.T) {
+	var out bytes.Buffer
+	l := riff.New(riff.Config{
+		Level:           riff.LevelInfo,
+		Output:          &out,
+		StackTraceLevel: riff.LevelFatal,
+		Layout:          riff.LayoutTable,
+	})
+	ctx := riff.WithContext(context.Background(), riff.Str("app", "api"))
+
+	l.Info(ctx, "Request", riff.Str("user", "bob"), riff.Int("n", 1))
+	l.Info(ctx, "Request", riff.Str("user", "alexander"), riff.Int("n", 22))
+	l.Info(ctx, "Request", riff.Str("user", "al"), riff.Int("n", 3))
+	l.Info(ctx, "Changed", riff.Str("id", "1"), riff.Int("n", 4))
+	exp := "INFO Request  user=bob n=1 app=api\n" +
+		"INFO Request  user=alexander n=22 app=api\n" +
+		"INFO Request  user=al        n=3  app=api\n" +
+		"INFO Changed  id=1 n=4 app=api\n"
+	if out.String() != exp {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		l.Info(ctx, "Request", riff.Str("user", "bob"), riff.Int("n", 1))
+	})
+	if allocs > 0 && !raceEnabled {
+		t.Errorf("Expected no allocations, got %.1f", allocs)
+	}
+}
+
+func TestMultilineLayout(t *testing.T) {
+	var out bytes.Buffer
+	l := riff.New(riff.Config{
+		Level:           riff.LevelInfo,
+		Output:          &out,
+		StackTraceLevel: riff.LevelFatal,
+		MinMessageWidth: 40,
+		Layout:          riff.LayoutMultiline,
+	})
+	ctx := riff.WithContext(context.Background(), riff.Str("app", "api"))
+
+	l.Info(ctx, "Request", riff.Str("user", "bob"), riff.Str("body", "line 1\nline 2"))
+	l.Event(ctx, riff.LevelInfo).Int("n", 1).Msg("Event")
+	exp := "INFO Request\n" +
+		"  user: bob\n" +
+		"  body: line 1\n" +
+		"    line 2\n" +
+		"  app: api\n" +
+		"INFO Event\n" +
+		"  n: 1\n" +
+		"  app: api\n"
+	if out.String() != exp {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+
+	out.Reset()
+	l.Info(ctx, "Stack", riff.Stack())
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) < 4 || lines[2] != "  stack:" {
+		t.Fatalf("Unexpected output %q", out.String())
+	}
+	for _, line := range lines[3:] {
+		if !strings.HasPrefix(line, "    ") {
+			t.Errorf("Expected stack trace line to be indented: %q", line)
+		}
+	}
+}
+
+func TestTheme(t *testing.T) {
+	var out bytes.Buffer
+	l := riff.New(riff.Config{
+		Level:           riff.LevelInfo,
+		Output:          &out,
+		Color:           true,
+		StackTraceLevel: riff.LevelFatal,
+		Theme: riff.Theme{
+			Levels:    map[riff.Level]riff.Style{riff.LevelInfo: riff.Color16(10)},
+			Key:       riff.Color256(244),
+			Highlight: map[string]riff.Style{"request_id": riff.Bold + riff.RGB(255, 128, 0)},
+			Message:   riff.Bold,
+			Number:    riff.Color16(5),
+			Error:     riff.Color16(1),
+		},
+	})
+	ctx := riff.WithContext(context.Background(), riff.Str("request_id", "r1"))
+
+	l.Info(ctx, "Done", riff.Int("n", 1), riff.Cause(errors.New("boom")), riff.Bool("ok", true))
+	exp := "\033[92mINFO\033[0m \033[1mDone\033[0m  " +
+		"\033[38;5;244mn\033[0m=\033[35m1\033[0m " +
+		"\033[38;5;244merror\033[0m=\033[31mboom\033[0m " +
+		"\033[38;5;244mok\033[0m=true " +
+		"\033[1m\033[38;2;255;128;0mrequest_id\033[0m=r1\n"
+	if out.String() != exp {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+
+	out.Reset()
+	l.Event(ctx, riff.LevelInfo).Int("n", 1).Msg("Done")
+	exp = "\033[92mINFO\033[0m \033[1mDone\033[0m  " +
+		"\033[38;5;244mn\033[0m=\033[35m1\033[0m " +
+		"\033[1m\033[38;2;255;128;0mrequest_id\033[0m=r1\n"
+	if out.String() != exp {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+}
+
+const (
+	levelNotice = riff.LevelInfo + 5
+	levelAudit  = riff.LevelFatal + 10
+)
+
+func TestCustomLevels(t *testing.T) {
+	// Levels stay registered when the test is run multiple times
+	if _, err := riff.ParseLevel("notice"); err != nil {
+		if err := riff.RegisterLevel(levelNotice, riff.LevelOptions{Name: "notice", Style: riff.Color16(4)}); err != nil {
+			t.Fatalf("Failed to register level: %v", err)
+		}
+		if err := riff.RegisterLevel(levelAudit, riff.LevelOptions{Name: "audit", Emoji: "📋"}); err != nil {
+			t.Fatalf("Failed to register level: %v", err)
+		}
+	}
+	if err := riff.RegisterLevel(riff.LevelInfo, riff.LevelOptions{Name: "information"}); err == nil {
+		t.Error("Expected an error for a registered level")
+	}
+	if err := riff.RegisterLevel(riff.LevelInfo+1, riff.LevelOptions{Name: "warning"}); err == nil {
+		t.Error("Expected an error for a registered name")
+	}
+	if lev, err := riff.ParseLevel("NOTICE"); err != nil || lev != levelNotice {
+		t.Errorf("Unexpected level %v, error %v", lev, err)
+	}
+
+	ctx := context.Background()
+	for _, tc := range []struct {
+		label riff.LevelLabel
+		exp   string
+	}{
+		{riff.LevelLabelShort, "INFO A\nNOTI B\nAUDI C\nlevel(99) D\n"},
+		{riff.LevelLabelFull, "info  A\nnotice B\naudit C\nlevel(99) D\n"},
+		{riff.LevelLabelLetter, "I A\nN B\nA C\nlevel(99) D\n"},
+		{riff.LevelLabelEmoji, "💬 A\n🔹 B\n📋 C\nlevel(99) D\n"},
+	} {
+		var out bytes.Buffer
+		l := riff.New(riff.Config{
+			Level:           riff.LevelInfo,
+			Output:          &out,
+			StackTraceLevel: riff.Level(1000),
+			LevelLabel:      tc.label,
+		})
+		l.Info(ctx, "A")
+		l.Event(ctx, levelNotice).Msg("B")
+		l.Event(ctx, levelAudit).Msg("C")
+		l.Event(ctx, riff.Level(99)).Msg("D")
+		l.Event(ctx, riff.LevelDebug+5).Msg("Below level")
+		if out.String() != tc.exp {
+			t.Errorf("Unexpected output %q", out.String())
+		}
+	}
+}
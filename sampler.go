@@ -0,0 +1,95 @@
+package riff
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Sampling caps the volume of repetitive entries. Within every Interval the
+// First entries with the same level and message are written, after that only
+// every Thereafter-th one is. Sampling is disabled when Interval is zero.
+type Sampling struct {
+	Interval   time.Duration
+	First      int
+	Thereafter int
+	// ByCaller groups entries by call site instead of level and message. The
+	// call site is located using StackTraceSkip.
+	ByCaller bool
+}
+
+// Number of counters used by the sampler. A counter belongs to the last key
+// that used it, an entry with a colliding key takes the counter over and starts
+// a new interval, so that unrelated entries are never sampled together.
+const samplerBuckets = 4096
+
+type sampler struct {
+	cfg      Sampling
+	counters [samplerBuckets]sampleCounter
+}
+
+type sampleCounter struct {
+	key     atomic.Uint64
+	resetAt atomic.Int64
+	count   atomic.Uint64
+}
+
+func newSampler(cfg Sampling) *sampler {
+	return &sampler{cfg: cfg}
+}
+
+// allow reports whether the entry should be written. The pc is only used when
+// sampling by caller.
+func (s *sampler) allow(lev Level, msg string, pc uintptr) bool {
+	var key uint64
+	if s.cfg.ByCaller {
+		key = hashUint(uint64(pc))
+	} else {
+		key = hashString(msg) ^ hashUint(uint64(lev))
+	}
+
+	n := s.counters[key%samplerBuckets].inc(key, time.Now().UnixNano(), s.cfg.Interval)
+	if n <= uint64(s.cfg.First) {
+		return true
+	}
+	return s.cfg.Thereafter > 0 && (n-uint64(s.cfg.First))%uint64(s.cfg.Thereafter) == 0
+}
+
+// inc increments the counter of the key and returns the new value. The counter
+// is reset once the interval is over or when it is taken over by another key.
+func (c *sampleCounter) inc(key uint64, now int64, interval time.Duration) uint64 {
+	resetAt := c.resetAt.Load()
+	if now < resetAt && c.key.Load() == key {
+		return c.count.Add(1)
+	}
+
+	c.key.Store(key)
+	c.count.Store(1)
+	if !c.resetAt.CompareAndSwap(resetAt, now+interval.Nanoseconds()) {
+		// Another goroutine has reset the counter first
+		return c.count.Add(1)
+	}
+	return 1
+}
+
+// hashString is an allocation-free FNV-1a hash function.
+func hashString(s string) uint64 {
+	const (
+		offset = 14695981039346656037
+		prime  = 1099511628211
+	)
+	h := uint64(offset)
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= prime
+	}
+	return h
+}
+
+// hashUint mixes the bits of an integer so that sequential values are spread
+// across the buckets.
+func hashUint(v uint64) uint64 {
+	v ^= v >> 33
+	v *= 0xff51afd7ed558ccd
+	v ^= v >> 33
+	return v
+}
@@ -0,0 +1,124 @@
+package riff
+
+import (
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Strs returns a field with the given key and a slice of strings value.
+// Slices are printed as [a,b,c].
+func Strs(key string, values []string) Field {
+	return field(key, func(b []byte) []byte {
+		return appendSlice(b, values, func(b []byte, v string) []byte {
+			return append(b, v...)
+		})
+	})
+}
+
+// Ints returns a field with the given key and a slice of ints value.
+func Ints(key string, values []int) Field {
+	return field(key, func(b []byte) []byte {
+		return appendSlice(b, values, func(b []byte, v int) []byte {
+			return strconv.AppendInt(b, int64(v), 10)
+		})
+	})
+}
+
+// Int64s returns a field with the given key and a slice of int64s value.
+func Int64s(key string, values []int64) Field {
+	return field(key, func(b []byte) []byte {
+		return appendSlice(b, values, func(b []byte, v int64) []byte {
+			return strconv.AppendInt(b, v, 10)
+		})
+	})
+}
+
+// Floats returns a field with the given key and a slice of float64s value.
+func Floats(key string, values []float64) Field {
+	return field(key, func(b []byte) []byte {
+		return appendSlice(b, values, func(b []byte, v float64) []byte {
+			return strconv.AppendFloat(b, v, 'f', -1, 64)
+		})
+	})
+}
+
+// Bools returns a field with the given key and a slice of booleans value.
+func Bools(key string, values []bool) Field {
+	return field(key, func(b []byte) []byte {
+		return appendSlice(b, values, strconv.AppendBool)
+	})
+}
+
+// Durations returns a field with the given key and a slice of time.Duration
+// value. Durations are truncated to the configured precision.
+func Durations(key string, values []time.Duration) Field {
+	return field(key, func(b []byte) []byte {
+		return appendSlice(b, values, func(b []byte, v time.Duration) []byte {
+			return append(b, v.Truncate(DurationPrecision).String()...)
+		})
+	})
+}
+
+// Times returns a field with the given key and a slice of time.Time value.
+// Times are formatted using the configured time format.
+func Times(key string, values []time.Time) Field {
+	return field(key, func(b []byte) []byte {
+		return appendSlice(b, values, func(b []byte, v time.Time) []byte {
+			return v.AppendFormat(b, TimeFormat)
+		})
+	})
+}
+
+// StrMap returns a field with the given key and a map of strings value. Maps
+// are printed as {a=1,b=2} with keys in sorted order.
+func StrMap(key string, values map[string]string) Field {
+	f := field(key, func(b []byte) []byte {
+		keys := make([]string, 0, len(values))
+		for k := range values {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+
+		b = append(b, '{')
+		for i, k := range keys {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			if MaxElements > 0 && i == MaxElements {
+				b = appendTruncated(b, len(keys)-i)
+				break
+			}
+			b = append(b, k...)
+			b = append(b, '=')
+			b = append(b, values[k]...)
+		}
+		return append(b, '}')
+	})
+	// Written the same way by appendReflect when redaction is configured
+	f.ext = &fieldExt{value: values, reflected: true}
+	return f
+}
+
+// appendSlice writes the values with the given function, truncating the list
+// to MaxElements.
+func appendSlice[T any](b []byte, values []T, fn func([]byte, T) []byte) []byte {
+	b = append(b, '[')
+	for i, v := range values {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		if MaxElements > 0 && i == MaxElements {
+			b = appendTruncated(b, len(values)-i)
+			break
+		}
+		b = fn(b, v)
+	}
+	return append(b, ']')
+}
+
+// appendTruncated writes the marker for n elements that are not printed.
+func appendTruncated(b []byte, n int) []byte {
+	b = append(b, "…+"...)
+	return strconv.AppendInt(b, int64(n), 10)
+}
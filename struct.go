@@ -0,0 +1,268 @@
+package riff
+
+import (
+	"cmp"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Values nested deeper than this are printed as {…}.
+const maxStructDepth = 5
+
+// Printed in place of the values of fields tagged with redact.
+const redacted = "[REDACTED]"
+
+// structPlan lists the printed fields of a struct type.
+type structPlan struct {
+	fields []structField
+}
+
+type structField struct {
+	index     int
+	name      string
+	omitEmpty bool
+	redact    bool
+}
+
+// Plans per struct type.
+var structPlans sync.Map
+
+var durationType = reflect.TypeFor[time.Duration]()
+
+// Struct returns a field with the given key and a struct value that is printed
+// as {name=value,name=value} using reflection. Only exported fields are
+// printed. Fields can be configured with the log tag:
+//
+//	ID       int    `log:"id"`         // Print as id
+//	Password string `log:"-"`          // Skip
+//	Comment  string `log:",omitempty"` // Skip if empty
+//	Token    string `log:",redact"`    // Print as [REDACTED]
+//
+// Values that implement marshaler interfaces, fmt.Stringer or error are written
+// by their methods. Pointers are followed, values nested too deep and cyclic
+// references are not printed.
+func Struct(key string, value any) Field {
+	f := field(key, func(b []byte) []byte {
+		return appendReflect(b, reflect.ValueOf(value), 0, nil, nil)
+	})
+	f.ext = &fieldExt{value: value, reflected: true}
+	return f
+}
+
+// appendReflect writes the value. Addresses of pointers and maps that are
+// being printed are kept to detect cycles. Key rules of the redactor, if given,
+// are applied to the names of struct fields and map keys.
+func appendReflect(b []byte, v reflect.Value, depth int, seen []uintptr, r *redactor) []byte {
+	if !v.IsValid() {
+		return append(b, "<nil>"...)
+	}
+
+	if v.Type() == durationType {
+		return append(b, time.Duration(v.Int()).Truncate(DurationPrecision).String()...)
+	}
+	// Methods of nil pointers are not called
+	if v.CanInterface() && !(v.Kind() == reflect.Pointer && v.IsNil()) {
+		i := v.Interface()
+		if t, ok := i.(time.Time); ok {
+			return t.AppendFormat(b, TimeFormat)
+		}
+		if b, ok := appendMethod(b, i); ok {
+			return b
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return append(b, v.String()...)
+	case reflect.Bool:
+		return strconv.AppendBool(b, v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.AppendInt(b, v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.AppendUint(b, v.Uint(), 10)
+	case reflect.Float32:
+		return strconv.AppendFloat(b, v.Float(), 'f', -1, 32)
+	case reflect.Float64:
+		return strconv.AppendFloat(b, v.Float(), 'f', -1, 64)
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return append(b, "<nil>"...)
+		}
+		if v.Kind() == reflect.Interface {
+			return appendReflect(b, v.Elem(), depth, seen, r)
+		}
+		if slices.Contains(seen, v.Pointer()) {
+			return append(b, "<cycle>"...)
+		}
+		return appendReflect(b, v.Elem(), depth, append(seen, v.Pointer()), r)
+	case reflect.Struct:
+		if depth >= maxStructDepth {
+			return append(b, "{…}"...)
+		}
+		return appendStruct(b, v, depth+1, seen, r)
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			return append(b, v.Bytes()...)
+		}
+		if depth >= maxStructDepth {
+			return append(b, "[…]"...)
+		}
+		b = append(b, '[')
+		for i := range v.Len() {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			if MaxElements > 0 && i == MaxElements {
+				b = appendTruncated(b, v.Len()-i)
+				break
+			}
+			b = appendReflect(b, v.Index(i), depth+1, seen, r)
+		}
+		return append(b, ']')
+	case reflect.Map:
+		if v.IsNil() {
+			return append(b, "{}"...)
+		}
+		if depth >= maxStructDepth {
+			return append(b, "{…}"...)
+		}
+		if slices.Contains(seen, v.Pointer()) {
+			return append(b, "<cycle>"...)
+		}
+		return appendMap(b, v, depth+1, append(seen, v.Pointer()), r)
+	default:
+		// Channels, functions and complex numbers
+		if !v.CanInterface() {
+			return append(b, v.Type().String()...)
+		}
+		return append(b, fmt.Sprint(v.Interface())...)
+	}
+}
+
+func appendStruct(b []byte, v reflect.Value, depth int, seen []uintptr, r *redactor) []byte {
+	plan := structPlanOf(v.Type())
+	b = append(b, '{')
+	var n int
+	for _, f := range plan.fields {
+		fv := v.Field(f.index)
+		if f.omitEmpty && fv.IsZero() {
+			continue
+		}
+		action, ok := RedactMask, f.redact
+		if !ok && r != nil {
+			action, ok = r.keyAction("", f.name)
+		}
+		if ok && action == RedactDrop {
+			continue
+		}
+		if n > 0 {
+			b = append(b, ',')
+		}
+		n++
+		b = append(b, f.name...)
+		b = append(b, '=')
+		b = appendReflectRedacted(b, fv, depth, seen, r, action, ok)
+	}
+	return append(b, '}')
+}
+
+// appendReflectRedacted writes the value of a struct field or a map entry with
+// the action of the matching key rule applied.
+func appendReflectRedacted(b []byte, v reflect.Value, depth int, seen []uintptr, r *redactor, action RedactAction, ok bool) []byte {
+	switch {
+	case !ok:
+		return appendReflect(b, v, depth, seen, r)
+	case action == RedactHash:
+		start := len(b)
+		b = appendReflect(b, v, depth, seen, r)
+		return r.appendHash(b, start)
+	default:
+		return append(b, redacted...)
+	}
+}
+
+// appendMap writes the map with keys in sorted order.
+func appendMap(b []byte, v reflect.Value, depth int, seen []uintptr, r *redactor) []byte {
+	type mapEntry struct {
+		key    string
+		value  reflect.Value
+		action RedactAction
+		redact bool
+	}
+	entries := make([]mapEntry, 0, v.Len())
+	for it := v.MapRange(); it.Next(); {
+		e := mapEntry{
+			key:   string(appendReflect(nil, it.Key(), depth, seen, nil)),
+			value: it.Value(),
+		}
+		if r != nil {
+			e.action, e.redact = r.keyAction("", e.key)
+		}
+		if e.redact && e.action == RedactDrop {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	slices.SortFunc(entries, func(a, b mapEntry) int {
+		return cmp.Compare(a.key, b.key)
+	})
+
+	b = append(b, '{')
+	for i, e := range entries {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		if MaxElements > 0 && i == MaxElements {
+			b = appendTruncated(b, len(entries)-i)
+			break
+		}
+		b = append(b, e.key...)
+		b = append(b, '=')
+		b = appendReflectRedacted(b, e.value, depth, seen, r, e.action, e.redact)
+	}
+	return append(b, '}')
+}
+
+// structPlanOf returns the cached plan for the struct type.
+func structPlanOf(t reflect.Type) *structPlan {
+	if p, ok := structPlans.Load(t); ok {
+		return p.(*structPlan)
+	}
+
+	var p structPlan
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		f := structField{index: i, name: sf.Name}
+		tag, ok := sf.Tag.Lookup("log")
+		if tag == "-" {
+			continue
+		}
+		if ok {
+			name, opts, _ := strings.Cut(tag, ",")
+			if name != "" {
+				f.name = name
+			}
+			for _, opt := range strings.Split(opts, ",") {
+				switch opt {
+				case "omitempty":
+					f.omitEmpty = true
+				case "redact":
+					f.redact = true
+				}
+			}
+		}
+		p.fields = append(p.fields, f)
+	}
+
+	v, _ := structPlans.LoadOrStore(t, &p)
+	return v.(*structPlan)
+}
@@ -0,0 +1,116 @@
+package riff
+
+import (
+	"slices"
+	"sync"
+)
+
+const (
+	// Only this many leading fields are aligned
+	maxTableColumns = 32
+	// A column shrinks to fit the latest value if no value has been as wide as
+	// the column for this many entries
+	tableDecay = 20
+)
+
+// table remembers column widths of recent entries. It is shared between a
+// logger and all the loggers derived from it.
+type table struct {
+	lock   sync.Mutex
+	keys   []uint64 // Hashes of the keys of the columns
+	widths []int
+	age    []int // Entries since a value was as wide as the column
+}
+
+// tableRow describes the fields of the entry that is being printed. It holds
+// hashes of the keys rather than the keys themselves, so that the fields don't
+// escape to the heap.
+type tableRow struct {
+	n    int
+	keys [maxTableColumns]uint64
+	ends [maxTableColumns]int // Offsets of the ends of the fields
+}
+
+func (r *tableRow) add(key string, end int) {
+	if r == nil || r.n == maxTableColumns {
+		return
+	}
+	r.keys[r.n] = hashString(key)
+	r.ends[r.n] = end
+	r.n++
+}
+
+// printColumn prints a field and records it in the row, if there is one.
+func (l *Logger) printColumn(buf *[]byte, lev Level, prefix string, f *Field, pad bool, row *tableRow) bool {
+	if !l.printFieldPrefixed(buf, lev, prefix, f, pad) {
+		return false
+	}
+	row.add(f.Key, len(*buf))
+	return true
+}
+
+// align pads the fields of the row that starts at the given offset to the
+// column widths. Widths and keys of the columns are updated with the row, if
+// the keys don't match the columns are reset.
+func (t *table) align(buf *[]byte, start int, row *tableRow) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	n := row.n
+	var widths [maxTableColumns]int
+	for i := range n {
+		from := start
+		if i > 0 {
+			from = row.ends[i-1]
+		}
+		widths[i] = displayWidth((*buf)[from:row.ends[i]])
+	}
+
+	if !slices.Equal(t.keys, row.keys[:n]) {
+		t.keys = append(t.keys[:0], row.keys[:n]...)
+		t.widths = append(t.widths[:0], widths[:n]...)
+		t.age = append(t.age[:0], make([]int, n)...)
+		return
+	}
+
+	var pads [maxTableColumns]int
+	var total int
+	for i := range n {
+		switch {
+		case widths[i] >= t.widths[i]:
+			t.widths[i], t.age[i] = widths[i], 0
+		case t.age[i] >= tableDecay:
+			t.widths[i], t.age[i] = widths[i], 0
+		default:
+			t.age[i]++
+		}
+		// The last column is not padded
+		if i < n-1 {
+			pads[i] = t.widths[i] - widths[i]
+			total += pads[i]
+		}
+	}
+	if total == 0 {
+		return
+	}
+
+	// Move the pieces that follow every padded field to the right, starting
+	// from the last one, and fill the gaps with spaces
+	end := len(*buf)
+	for range total {
+		*buf = append(*buf, ' ')
+	}
+	b := *buf
+	shift := total
+	for i := n - 2; i >= 0; i-- {
+		if shift == 0 {
+			break
+		}
+		copy(b[row.ends[i]+shift:], b[row.ends[i]:end])
+		for j := range pads[i] {
+			b[row.ends[i]+shift-pads[i]+j] = ' '
+		}
+		shift -= pads[i]
+		end = row.ends[i]
+	}
+}
@@ -0,0 +1,103 @@
+package riff
+
+import (
+	"context"
+	"sync"
+)
+
+// Entries at this level or above flush the tail buffer of their context.
+const tailFlushLevel = LevelError
+
+type tailKey struct{}
+
+// tailBuffer is a ring buffer of encoded entries.
+type tailBuffer struct {
+	lock    sync.Mutex
+	entries [][]byte
+	next    int
+	full    bool
+}
+
+// WithBuffer returns a context that holds up to capacity most recent entries
+// that are below the logger level. If an entry at Error level or above is
+// logged with the context, the buffered entries are written before it.
+// Otherwise they are discarded along with the context. Buffering is only
+// performed by loggers with ContextBuffers enabled.
+func WithBuffer(ctx context.Context, capacity int) context.Context {
+	if capacity <= 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, tailKey{}, &tailBuffer{
+		entries: make([][]byte, capacity),
+	})
+}
+
+func tailFromContext(ctx context.Context) *tailBuffer {
+	t, _ := ctx.Value(tailKey{}).(*tailBuffer)
+	return t
+}
+
+// push copies the entry into the buffer, overwriting the oldest one if the
+// buffer is full.
+func (t *tailBuffer) push(entry []byte) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.entries[t.next] = append(t.entries[t.next][:0], entry...)
+	t.next++
+	if t.next == len(t.entries) {
+		t.next = 0
+		t.full = true
+	}
+}
+
+// drain calls fn for every buffered entry in order and empties the buffer.
+func (t *tailBuffer) drain(fn func(entry []byte)) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.full {
+		for _, e := range t.entries[t.next:] {
+			fn(e)
+		}
+	}
+	for _, e := range t.entries[:t.next] {
+		fn(e)
+	}
+	t.next = 0
+	t.full = false
+}
+
+// bufferEntry encodes the entry into the tail buffer.
+func (l *Logger) bufferEntry(t *tailBuffer, e *entry) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	l.printEntry(buf, e)
+	t.push(*buf)
+}
+
+// flushTail writes the entries buffered in the context.
+func (l *Logger) flushTail(ctx context.Context) {
+	t := tailFromContext(ctx)
+	if t == nil {
+		return
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.dedup != nil {
+		l.flushRepeats()
+	}
+	var drained bool
+	t.drain(func(entry []byte) {
+		l.cfg.Output.Write(entry)
+		drained = true
+	})
+	// Buffered entries break the run of duplicates, so the next entry is not a
+	// repeat of the one written before them
+	if drained && l.dedup != nil {
+		l.dedup.last = l.dedup.last[:0]
+	}
+}
@@ -0,0 +1,160 @@
+package riff
+
+import (
+	"strings"
+	"sync"
+)
+
+// messageTemplate is a parsed message with {key} placeholders.
+type messageTemplate struct {
+	parts []templatePart
+}
+
+// templatePart is either a literal piece of the message or a placeholder.
+type templatePart struct {
+	text        string
+	placeholder bool // Text is the key of a field
+}
+
+// Parsed templates. Messages are normally string literals, but to be safe the
+// cache size is capped. A plain map is used so that looking up a message
+// doesn't make it escape to the heap along with the fields.
+var (
+	messageTemplates     = map[string]*messageTemplate{}
+	messageTemplatesLock sync.RWMutex
+)
+
+const maxMessageTemplates = 1024
+
+// printTemplate prints the message with placeholders replaced by values of the
+// matching fields. Placeholders without a matching field are left as is.
+// Fields encoded by an Event can't be matched.
+func (l *Logger) printTemplate(buf *[]byte, msg string, fields, ctxFields []Field) {
+	if strings.IndexByte(msg, '{') < 0 {
+		*buf = append(*buf, msg...)
+		return
+	}
+
+	for _, p := range getTemplate(msg).parts {
+		if !p.placeholder {
+			*buf = append(*buf, p.text...)
+			continue
+		}
+		f, ok := findField(p.text, l.group, fields, ctxFields)
+		if ok && (f.kind() == kindSecret || l.redactor != nil) {
+			prefix, _ := cutLast(p.text, '.')
+			ok = l.appendRedacted(buf, prefix, &f)
+		} else if ok {
+			*buf = f.ValueFn(*buf)
+		}
+		if !ok {
+			*buf = append(*buf, '{')
+			*buf = append(*buf, p.text...)
+			*buf = append(*buf, '}')
+		}
+	}
+}
+
+// cutLast slices the string around the last instance of the separator.
+func cutLast(s string, sep byte) (before, after string) {
+	if i := strings.LastIndexByte(s, sep); i >= 0 {
+		return s[:i], s[i+1:]
+	}
+	return "", s
+}
+
+func getTemplate(msg string) *messageTemplate {
+	messageTemplatesLock.RLock()
+	t, ok := messageTemplates[msg]
+	messageTemplatesLock.RUnlock()
+	if ok {
+		return t
+	}
+
+	owned := strings.Clone(msg)
+	t = parseTemplate(owned)
+	messageTemplatesLock.Lock()
+	if len(messageTemplates) < maxMessageTemplates {
+		messageTemplates[owned] = t
+	}
+	messageTemplatesLock.Unlock()
+	return t
+}
+
+// parseTemplate splits the message into literals and placeholders. A
+// placeholder is a key made of letters, digits, dots, dashes and underscores
+// wrapped in braces, anything else is a literal.
+func parseTemplate(msg string) *messageTemplate {
+	var t messageTemplate
+	var lit int // Start of the current literal
+	for i := 0; i < len(msg); i++ {
+		if msg[i] != '{' {
+			continue
+		}
+		end := strings.IndexByte(msg[i+1:], '}')
+		if end <= 0 || !isTemplateKey(msg[i+1:i+1+end]) {
+			continue
+		}
+
+		if lit < i {
+			t.parts = append(t.parts, templatePart{text: msg[lit:i]})
+		}
+		t.parts = append(t.parts, templatePart{text: msg[i+1 : i+1+end], placeholder: true})
+		i += end + 1
+		lit = i + 1
+	}
+	if lit < len(msg) {
+		t.parts = append(t.parts, templatePart{text: msg[lit:]})
+	}
+	return &t
+}
+
+func isTemplateKey(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_' || c == '.' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// findField returns the first value field with the given key, looking in the
+// entry fields first. Fields in groups are found by their dotted keys, entry
+// fields of a grouped logger are prefixed with the group of the logger.
+func findField(key, group string, fields, ctxFields []Field) (Field, bool) {
+	entryKey, ok := key, true
+	if group != "" {
+		entryKey, ok = cutGroup(key, group)
+	}
+	if ok {
+		if f, ok := findFieldIn(entryKey, fields); ok {
+			return f, true
+		}
+	}
+	return findFieldIn(key, ctxFields)
+}
+
+// cutGroup returns the key relative to the group if the key is in the group.
+func cutGroup(key, group string) (string, bool) {
+	if len(key) > len(group) && key[len(group)] == '.' && strings.HasPrefix(key, group) {
+		return key[len(group)+1:], true
+	}
+	return "", false
+}
+
+func findFieldIn(key string, fields []Field) (Field, bool) {
+	for _, f := range fields {
+		switch {
+		case f.isValue() && f.Key == key:
+			return f, true
+		case f.kind() == kindGroup:
+			if rest, ok := cutGroup(key, f.Key); ok {
+				if f, ok := findFieldIn(rest, f.ext.group); ok {
+					return f, true
+				}
+			}
+		}
+	}
+	return Field{}, false
+}
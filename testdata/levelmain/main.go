@@ -0,0 +1,29 @@
+// Command levelmain logs from the main package with the level spec given as
+// the argument, it is run by the level spec tests.
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/localhots/riff"
+)
+
+func main() {
+	spec, err := riff.ParseLevelSpec(os.Args[1])
+	if err != nil {
+		panic(err)
+	}
+	l := riff.New(riff.Config{
+		Level:           riff.LevelInfo,
+		Output:          os.Stdout,
+		StackTraceLevel: riff.LevelFatal,
+		StackTraceSkip:  3,
+		LevelSpec:       spec,
+	})
+	ctx := context.Background()
+
+	l.Debug(ctx, "Main")
+	l.Named("db").Debug(ctx, "DB")
+	l.Info(ctx, "Info")
+}
@@ -0,0 +1,222 @@
+package riff
+
+import (
+	"strconv"
+)
+
+// Style is an ANSI escape sequence that sets the color and other attributes of
+// the text that follows it. Styles can be combined by concatenation, e.g.
+// Bold + Color256(208). The empty style leaves the text as is.
+type Style string
+
+const (
+	Bold      Style = "\033[1m"
+	Dim       Style = "\033[2m"
+	Italic    Style = "\033[3m"
+	Underline Style = "\033[4m"
+)
+
+// Color16 returns a style with one of the 16 basic foreground colors. Colors
+// 0 to 7 are black, red, green, yellow, blue, magenta, cyan and white, colors
+// 8 to 15 are their bright versions.
+func Color16(n uint8) Style {
+	return sgr(ansi16(n, 30, 90))
+}
+
+// BgColor16 returns a style with one of the 16 basic background colors.
+func BgColor16(n uint8) Style {
+	return sgr(ansi16(n, 40, 100))
+}
+
+// Color256 returns a style with a foreground color from the 256-color palette.
+func Color256(n uint8) Style {
+	return sgr("38;5;" + strconv.Itoa(int(n)))
+}
+
+// BgColor256 returns a style with a background color from the 256-color
+// palette.
+func BgColor256(n uint8) Style {
+	return sgr("48;5;" + strconv.Itoa(int(n)))
+}
+
+// RGB returns a style with a truecolor foreground color.
+func RGB(r, g, b uint8) Style {
+	return sgr("38;2;" + rgb(r, g, b))
+}
+
+// BgRGB returns a style with a truecolor background color.
+func BgRGB(r, g, b uint8) Style {
+	return sgr("48;2;" + rgb(r, g, b))
+}
+
+func ansi16(n uint8, normal, bright int) string {
+	if n < 8 {
+		return strconv.Itoa(normal + int(n))
+	}
+	return strconv.Itoa(bright + int(n%16) - 8)
+}
+
+func rgb(r, g, b uint8) string {
+	return strconv.Itoa(int(r)) + ";" + strconv.Itoa(int(g)) + ";" + strconv.Itoa(int(b))
+}
+
+func sgr(params string) Style {
+	return Style("\033[" + params + "m")
+}
+
+// Theme configures the colors of the output when Color is enabled. Empty styles
+// leave the text as is, except for levels and keys which fall back to the
+// default colors of the level.
+type Theme struct {
+	// Levels are the styles of level names. Keys are printed in the style of
+	// the level too, unless Key is set.
+	Levels map[Level]Style
+	Key    Style
+	// Highlight sets the styles of specific keys, such as "request_id".
+	Highlight map[string]Style
+
+	Time     Style
+	Message  Style
+	Number   Style
+	String   Style
+	Error    Style
+	Duration Style
+}
+
+// DefaultTheme returns the theme that is used when none is configured.
+func DefaultTheme() Theme {
+	return Theme{
+		Levels: map[Level]Style{
+			LevelTrace: colorOffWhite,
+			LevelDebug: colorOffWhite,
+			LevelInfo:  colorCyan,
+			LevelWarn:  colorYellow,
+			LevelError: colorRed,
+			LevelPanic: colorRedBg + colorWhite,
+			LevelFatal: colorRedBg + colorWhite,
+		},
+	}
+}
+
+// HighContrastTheme returns a theme with bold, bright colors for terminals with
+// poor color reproduction.
+func HighContrastTheme() Theme {
+	return Theme{
+		Levels: map[Level]Style{
+			LevelTrace: Color16(15),
+			LevelDebug: Color16(15),
+			LevelInfo:  Bold + Color16(14),
+			LevelWarn:  Bold + Color16(11),
+			LevelError: Bold + Color16(9),
+			LevelPanic: Bold + BgColor16(1) + Color16(15),
+			LevelFatal: Bold + BgColor16(1) + Color16(15),
+		},
+		Key:      Bold,
+		Error:    Bold + Color16(9),
+		Duration: Color16(11),
+	}
+}
+
+// SolarizedTheme returns a theme with the Solarized palette, it requires a
+// terminal with truecolor support.
+func SolarizedTheme() Theme {
+	var (
+		base01  = RGB(0x58, 0x6e, 0x75)
+		yellow  = RGB(0xb5, 0x89, 0x00)
+		red     = RGB(0xdc, 0x32, 0x2f)
+		magenta = RGB(0xd3, 0x36, 0x82)
+		violet  = RGB(0x6c, 0x71, 0xc4)
+		blue    = RGB(0x26, 0x8b, 0xd2)
+		cyan    = RGB(0x2a, 0xa1, 0x98)
+		green   = RGB(0x85, 0x99, 0x00)
+	)
+	return Theme{
+		Levels: map[Level]Style{
+			LevelTrace: base01,
+			LevelDebug: base01,
+			LevelInfo:  blue,
+			LevelWarn:  yellow,
+			LevelError: red,
+			LevelPanic: BgRGB(0xdc, 0x32, 0x2f) + RGB(0xfd, 0xf6, 0xe3),
+			LevelFatal: BgRGB(0xdc, 0x32, 0x2f) + RGB(0xfd, 0xf6, 0xe3),
+		},
+		Key:      cyan,
+		Time:     base01,
+		Number:   violet,
+		String:   green,
+		Error:    red,
+		Duration: magenta,
+	}
+}
+
+// valueType is the type of a field value, it selects the style of the value.
+type valueType uint8
+
+const (
+	typeOther valueType = iota
+	typeNumber
+	typeString
+	typeError
+	typeDuration
+)
+
+// levelStyle returns the style of the level name.
+func (l *Logger) levelStyle(lev Level) Style {
+	if s, ok := l.cfg.Theme.Levels[lev]; ok {
+		return s
+	}
+	if d := levelDescOf(lev); d != nil {
+		return d.style
+	}
+	return ""
+}
+
+// keyStyle returns the style of the key.
+func (l *Logger) keyStyle(lev Level, key string) Style {
+	if s, ok := l.cfg.Theme.Highlight[key]; ok {
+		return s
+	}
+	if l.cfg.Theme.Key != "" {
+		return l.cfg.Theme.Key
+	}
+	return l.levelStyle(lev)
+}
+
+// valueStyle returns the style of the value of the type.
+func (l *Logger) valueStyle(typ valueType) Style {
+	switch typ {
+	case typeNumber:
+		return l.cfg.Theme.Number
+	case typeString:
+		return l.cfg.Theme.String
+	case typeError:
+		return l.cfg.Theme.Error
+	case typeDuration:
+		return l.cfg.Theme.Duration
+	default:
+		return ""
+	}
+}
+
+// writeStyled prints the string in the style if colors are enabled.
+func (l *Logger) writeStyled(buf *[]byte, s Style, str string) {
+	styled := l.startStyle(buf, s)
+	*buf = append(*buf, str...)
+	l.endStyle(buf, styled)
+}
+
+// startStyle prints the style if colors are enabled and reports whether it
+// needs to be reset with endStyle.
+func (l *Logger) startStyle(buf *[]byte, s Style) bool {
+	if !l.cfg.Color || s == "" {
+		return false
+	}
+	*buf = append(*buf, s...)
+	return true
+}
+
+func (l *Logger) endStyle(buf *[]byte, styled bool) {
+	if styled {
+		*buf = append(*buf, colorReset...)
+	}
+}
@@ -1,8 +1,8 @@
 package riff
 
 import (
-	"fmt"
 	"strconv"
+	"sync"
 	"time"
 )
 
@@ -10,19 +10,63 @@ import (
 type Field struct {
 	Key     string
 	ValueFn ValueFn
+
+	// The rest is kept behind a pointer. A field of up to 4 words is built by
+	// the compiler right in the arguments of a logging call rather than copied
+	// there, which matters on the hot path.
+	ext *fieldExt
+}
+
+// fieldExt holds the parts of a field other than the key and the value. Fields
+// that only differ by kind or value type share static descriptions, so that
+// building them doesn't allocate. Shared descriptions must not be modified.
+type fieldExt struct {
+	kind  fieldKind
+	typ   valueType
+	group []Field
+	// Value of a Struct, StrMap or Any field that is written again with key
+	// rules applied to the names of its fields if redaction is configured
+	value     any
+	reflected bool // Value is written by appendReflect rather than appendAny
 }
 
+// fieldKind tells regular key-value fields apart from the special ones that
+// control how the entry is printed and are not printed themselves.
+type fieldKind uint8
+
+const (
+	kindValue fieldKind = iota
+	kindSecret
+	kindGroup
+	kindStack
+	kindStackAll
+	kindNoStack
+)
+
+// StackScope selects the goroutines included in a stack trace.
+type StackScope int
+
+const (
+	// StackCurrent includes the stack of the logging goroutine only.
+	StackCurrent StackScope = iota
+	// StackAll includes the stacks of all running goroutines.
+	StackAll
+)
+
 // ValueFn writes to the given byte slice and returns the result.
 type ValueFn func([]byte) []byte
 
 // Cause returns a field that wraps the given error in a standardized way.
 func Cause(err error) Field {
-	return Str("error", err.Error())
+	msg := err.Error()
+	return typedField("error", typeError, func(b []byte) []byte {
+		return append(b, msg...)
+	})
 }
 
 // Str returns a field with the given key and a string value.
 func Str(key, value string) Field {
-	return field(key, func(b []byte) []byte {
+	return typedField(key, typeString, func(b []byte) []byte {
 		return append(b, value...)
 	})
 }
@@ -34,7 +78,7 @@ func Int(key string, value int) Field {
 
 // Int64 returns a field with the given key and an int64 value.
 func Int64(key string, value int64) Field {
-	return field(key, func(b []byte) []byte {
+	return typedField(key, typeNumber, func(b []byte) []byte {
 		return strconv.AppendInt(b, value, 10)
 	})
 }
@@ -46,7 +90,7 @@ func Uint(key string, value uint) Field {
 
 // Uint64 returns a field with the given key and a uint64 value.
 func Uint64(key string, value uint64) Field {
-	return field(key, func(b []byte) []byte {
+	return typedField(key, typeNumber, func(b []byte) []byte {
 		return strconv.AppendUint(b, value, 10)
 	})
 }
@@ -60,14 +104,14 @@ func Bool(key string, value bool) Field {
 
 // Float64 returns a field with the given key and a float64 value.
 func Float64(key string, value float64) Field {
-	return field(key, func(b []byte) []byte {
+	return typedField(key, typeNumber, func(b []byte) []byte {
 		return strconv.AppendFloat(b, value, 'f', -1, 64)
 	})
 }
 
 // Float32 returns a field with the given key and a float32 value.
 func Float32(key string, value float32) Field {
-	return field(key, func(b []byte) []byte {
+	return typedField(key, typeNumber, func(b []byte) []byte {
 		return strconv.AppendFloat(b, float64(value), 'f', -1, 32)
 	})
 }
@@ -76,7 +120,7 @@ func Float32(key string, value float32) Field {
 // The duration is truncated to the configured precision (default is
 // milliseconds).
 func Duration(key string, value time.Duration) Field {
-	return field(key, func(b []byte) []byte {
+	return typedField(key, typeDuration, func(b []byte) []byte {
 		return append(b, value.Truncate(DurationPrecision).String()...)
 	})
 }
@@ -89,9 +133,34 @@ func Time(key string, value time.Time) Field {
 	})
 }
 
+// Lazy returns a field with the given key and a value that is computed by the
+// given function only when the entry is being written. The value is handled
+// the same way Any handles it. The function is called at most once, the value
+// is reused if the field is written again, e.g. by a message template.
+func Lazy(key string, fn func() any) Field {
+	value := sync.OnceValue(fn)
+	return field(key, func(b []byte) []byte {
+		return Any(key, value()).ValueFn(b)
+	})
+}
+
+// LazyStr returns a field with the given key and a string value that is
+// computed by the given function only when the entry is being written. Like
+// with Lazy, the function is called at most once.
+func LazyStr(key string, fn func() string) Field {
+	value := sync.OnceValue(fn)
+	return field(key, func(b []byte) []byte {
+		return append(b, value()...)
+	})
+}
+
 // Any returns a field with the given key and an any value. For most built-in
-// types the value is written to the buffer using most effcient method, for
-// other types the value is converted to a string using fmt.Sprint.
+// types the value is written to the buffer using most effcient method. Other
+// values are written by the first method they implement out of LogObject,
+// LogArray, MarshalText, Format, String, Error and MarshalJSON, panics of the
+// methods are recovered. Structs are written by Struct, pointers are followed
+// and nil is written as <nil>. The rest is converted to a string using
+// fmt.Sprint.
 func Any(key string, value any) Field {
 	switch v := value.(type) {
 	case string:
@@ -128,11 +197,127 @@ func Any(key string, value any) Field {
 		return Duration(key, v)
 	case time.Time:
 		return Time(key, v)
+	case []string:
+		return Strs(key, v)
+	case []int:
+		return Ints(key, v)
+	case []int64:
+		return Int64s(key, v)
+	case []float64:
+		return Floats(key, v)
+	case []bool:
+		return Bools(key, v)
+	case []time.Duration:
+		return Durations(key, v)
+	case []time.Time:
+		return Times(key, v)
+	case map[string]string:
+		return StrMap(key, v)
+	case ObjectMarshaler:
+		return Object(key, v)
+	case ArrayMarshaler:
+		return Array(key, v)
+	case error:
+		return typedField(key, typeError, func(b []byte) []byte {
+			return appendAny(b, v, nil)
+		})
 	default:
-		// TODO: Add support for custom encoders
-		return field(key, func(b []byte) []byte {
-			return append(b, fmt.Sprint(value)...)
+		f := field(key, func(b []byte) []byte {
+			return appendAny(b, value, nil)
 		})
+		f.ext = &fieldExt{value: value}
+		return f
+	}
+}
+
+// Group returns a field that groups the given fields under the key. Keys of
+// the grouped fields are prefixed with the group key when printed, e.g.
+// Group("http", Str("method", "GET")) is printed as http.method=GET.
+func Group(key string, fields ...Field) Field {
+	return Field{
+		Key: key,
+		ext: &fieldExt{kind: kindGroup, group: fields},
+	}
+}
+
+// Stack returns a field that forces a stack trace of the current goroutine to
+// be printed with the entry regardless of the configured StackTraceLevel.
+func Stack() Field {
+	return StackOf(StackCurrent)
+}
+
+// AllGoroutines returns a field that forces the stacks of all goroutines to be
+// printed with the entry. It is meant for diagnosing hangs and deadlocks.
+func AllGoroutines() Field {
+	return StackOf(StackAll)
+}
+
+// StackOf returns a field that forces a stack trace of the given scope to be
+// printed with the entry.
+func StackOf(scope StackScope) Field {
+	if scope == StackAll {
+		return Field{ext: &kindExts[kindStackAll]}
+	}
+	return Field{ext: &kindExts[kindStack]}
+}
+
+// NoStack returns a field that suppresses the stack trace for the entry, even
+// if its level is at or above the configured StackTraceLevel.
+func NoStack() Field {
+	return Field{ext: &kindExts[kindNoStack]}
+}
+
+// Shared descriptions of fields of the kinds and value types that need no
+// other data.
+var (
+	kindExts = [...]fieldExt{
+		kindStack:    {kind: kindStack},
+		kindStackAll: {kind: kindStackAll},
+		kindNoStack:  {kind: kindNoStack},
+	}
+	typeExts = [...]fieldExt{
+		typeNumber:   {typ: typeNumber},
+		typeString:   {typ: typeString},
+		typeError:    {typ: typeError},
+		typeDuration: {typ: typeDuration},
+	}
+)
+
+// kind returns the kind of the field.
+func (f Field) kind() fieldKind {
+	if f.ext == nil {
+		return kindValue
+	}
+	return f.ext.kind
+}
+
+// typ returns the type of the field value.
+func (f Field) typ() valueType {
+	if f.ext == nil {
+		return typeOther
+	}
+	return f.ext.typ
+}
+
+// isValue reports whether the field is a key-value pair.
+func (f Field) isValue() bool {
+	k := f.kind()
+	return k == kindValue || k == kindSecret
+}
+
+// isDirective reports whether the field is a stack trace directive.
+func (f Field) isDirective() bool {
+	k := f.kind()
+	return k == kindStack || k == kindStackAll || k == kindNoStack
+}
+
+// typedField returns a field with a value of the given type, the type selects
+// the style of the value.
+func typedField(key string, typ valueType, fn ValueFn) Field {
+	return Field{
+		Key:     key,
+		ValueFn: fn,
+		ext:     &typeExts[typ],
 	}
 }
 
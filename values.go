@@ -0,0 +1,118 @@
+package riff
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// Stringer returns a field with the given key and a value that is converted
+// to a string by its String method only when the entry is being written.
+func Stringer(key string, value fmt.Stringer) Field {
+	return field(key, func(b []byte) []byte {
+		return appendStringer(b, value)
+	})
+}
+
+// appendAny writes values that are not handled by the typed constructors, in
+// the order of preference: marshalers, text marshalers, formatters, stringers,
+// errors, JSON marshalers, structs and pointers. Everything else is written
+// using fmt.Sprint. Key rules of the redactor, if given, are applied to the
+// names of struct fields and map keys.
+func appendAny(b []byte, value any, r *redactor) []byte {
+	if value == nil {
+		return append(b, "<nil>"...)
+	}
+	if b, ok := appendMethod(b, value); ok {
+		return b
+	}
+
+	rv := reflect.ValueOf(value)
+	switch {
+	case rv.Kind() == reflect.Pointer && rv.IsNil():
+		return append(b, "<nil>"...)
+	case rv.Kind() == reflect.Struct || rv.Kind() == reflect.Pointer && rv.Elem().Kind() == reflect.Struct:
+		return appendReflect(b, rv, 0, nil, r)
+	case rv.Kind() == reflect.Pointer:
+		return appendAny(b, rv.Elem().Interface(), r)
+	default:
+		return fmt.Append(b, value)
+	}
+}
+
+// appendMethod writes the value using the first method it implements. It
+// reports false if the value implements none.
+func appendMethod(b []byte, value any) ([]byte, bool) {
+	switch v := value.(type) {
+	case ObjectMarshaler:
+		return appendObject(b, v), true
+	case ArrayMarshaler:
+		return appendArray(b, v), true
+	case encoding.TextMarshaler:
+		return appendText(b, v), true
+	case fmt.Formatter:
+		// fmt recovers panics by itself
+		return fmt.Append(b, v), true
+	case fmt.Stringer:
+		return appendStringer(b, v), true
+	case error:
+		return appendError(b, v), true
+	case json.Marshaler:
+		return appendJSON(b, v), true
+	default:
+		return b, false
+	}
+}
+
+func appendText(b []byte, v encoding.TextMarshaler) (res []byte) {
+	defer recoverValue(b, v, &res)
+	text, err := v.MarshalText()
+	if err != nil {
+		return appendMarshalError(b, err)
+	}
+	return append(b, text...)
+}
+
+func appendJSON(b []byte, v json.Marshaler) (res []byte) {
+	defer recoverValue(b, v, &res)
+	data, err := v.MarshalJSON()
+	if err != nil {
+		return appendMarshalError(b, err)
+	}
+	return append(b, data...)
+}
+
+func appendStringer(b []byte, v fmt.Stringer) (res []byte) {
+	if v == nil {
+		return append(b, "<nil>"...)
+	}
+	defer recoverValue(b, v, &res)
+	return append(b, v.String()...)
+}
+
+func appendError(b []byte, v error) (res []byte) {
+	defer recoverValue(b, v, &res)
+	return append(b, v.Error()...)
+}
+
+func appendMarshalError(b []byte, err error) []byte {
+	b = append(b, "<error: "...)
+	b = append(b, err.Error()...)
+	return append(b, '>')
+}
+
+// recoverValue recovers a panic of a method that writes the value. The output
+// is replaced with <nil> if the method was called on a nil pointer and with
+// the panic message otherwise. It must be deferred.
+func recoverValue(b []byte, v any, res *[]byte) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Pointer && rv.IsNil() {
+		*res = append(b, "<nil>"...)
+		return
+	}
+	*res = fmt.Appendf(b, "<panic: %v>", r)
+}